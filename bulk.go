@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// A bulk capture has one todo per line, read by 'todo add -' from stdin or
+// by 'todo add -f <file>'. Blank lines and lines starting with # are skipped
+// and list markers such as "- ", "* " or "- [ ] " are removed, so notes can
+// be pasted as they are. "[x]" adds the todo as complete.
+
+type bulkLine struct {
+	number    int
+	name      string
+	completed bool
+}
+
+var listMarker = regexp.MustCompile(`^(?:[-*]\s+)?(?:\[([ xX])\]\s+)?`)
+
+// readBulk returns the todos in r and whether any line was indented
+func readBulk(r io.Reader) ([]bulkLine, bool, error) {
+	var lines []bulkLine
+	indented := false
+	scanner := bufio.NewScanner(r)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(text, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if len(trimmed) < len(text) {
+			indented = true
+		}
+		line := bulkLine{number: number}
+		m := listMarker.FindStringSubmatch(trimmed)
+		line.completed = m[1] == "x" || m[1] == "X"
+		line.name = trimmed[len(m[0]):]
+		if line.name == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, indented, scanner.Err()
+}
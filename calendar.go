@@ -0,0 +1,252 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// todo agenda lists what is due day by day and todo cal shows a month at a
+// glance. Only the due date is shown, there is no scheduled date. Both start
+// weeks on the weekstart config key and show dates in the timezone config
+// key, see setupDates.
+
+// calendarOptions are the flags shared by agenda and cal
+type calendarOptions struct {
+	status string
+}
+
+func (o *calendarOptions) register(f *flag.FlagSet) {
+	f.StringVar(&o.status, "s", "incomplete", "Status of todo (incomplete | complete | all)")
+}
+
+// dueTodos returns the todos matching -s and the filter expression given
+// after the flags that are due from start until end, sooner first
+func (o *calendarOptions) dueTodos(d *DbTable, f *flag.FlagSet, start time.Time, end time.Time) []todo {
+	filter, err := parseFilter(strings.Join(f.Args(), " "))
+	if err == nil {
+		err = filterStatus(filter, o.status)
+	}
+	if err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+	if err = d.checkFilter(filter); err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+	filter.and("due != 0 AND due >= ? AND due < ?", start.Unix(), end.Unix())
+	todos := d.queryTodos(filter, -1)
+	sort.SliceStable(todos, func(i, j int) bool { return todos[i].due < todos[j].due })
+	return todos
+}
+
+// priorityMarker shows the priority as up to three !
+func priorityMarker(p Priority) string {
+	return padText(strings.Repeat("!", int(p)), 3, false)
+}
+
+func agendaCmd(d *DbTable, f *flag.FlagSet) {
+	o := &calendarOptions{}
+	o.register(f)
+	days := f.Int("days", 7, "Number of days to show")
+	from := f.String("from", "today", "First day to show (e.g. tomorrow, next mon, 2026-11-01)")
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: todo agenda [flags] [filter expression]")
+		fmt.Fprintln(f.Output(), "  e.g. todo agenda -days 14 'tags = work'")
+		f.PrintDefaults()
+	}
+	f.Parse(os.Args[2:])
+	start, err := parseDate(*from)
+	if err != nil {
+		fmt.Println("Error reading -from: ", err)
+		os.Exit(1)
+	}
+	if *days < 1 {
+		fmt.Println("Error: -days must be at least 1")
+		os.Exit(1)
+	}
+	start = startOfDay(start)
+	end := start.AddDate(0, 0, *days)
+	now := time.Now()
+
+	// Overdue todos are pinned at the top however long ago they were due
+	var overdue []todo
+	byDay := map[string][]todo{}
+	for _, t := range o.dueTodos(d, f, time.Unix(0, 0), end) {
+		due, _ := t.dueTime()
+		switch {
+		case t.overdue(now):
+			overdue = append(overdue, t)
+		case !due.Before(start):
+			day := due.Format("2006-01-02")
+			byDay[day] = append(byDay[day], t)
+		}
+	}
+
+	c := newConsolePrint(d)
+	c.printContextHeader()
+	if len(overdue) > 0 {
+		fmt.Println(c.color["overdue"] + "Overdue" + c.color["reset"])
+		for _, t := range overdue {
+			due, _ := t.dueTime()
+			c.printAgendaTodo(t, relativeDate(due, now))
+		}
+	}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == weekStart && day != start {
+			fmt.Println(c.color["border"] + strings.Repeat("─", minInt(maxInt(c.width, 10), 40)) + c.color["reset"])
+		}
+		heading := day.Format("Mon 2 Jan")
+		if relative := relativeDate(day, now); relative == "today" || relative == "tomorrow" {
+			heading += "  " + relative
+		}
+		fmt.Println(c.color["header"] + heading + c.color["reset"])
+		todos := byDay[day.Format("2006-01-02")]
+		if len(todos) == 0 {
+			fmt.Println("  " + c.color["completed"] + "nothing due" + c.color["reset"])
+		}
+		for _, t := range todos {
+			due, _ := t.dueTime()
+			clock := ""
+			if due.Hour() != 0 || due.Minute() != 0 {
+				clock = due.Format("15:04")
+			}
+			c.printAgendaTodo(t, clock)
+		}
+	}
+}
+
+// printAgendaTodo prints a line of the agenda, when the todo is due
+// followed by its id, priority, name, tags and context
+func (c ConsolePrint) printAgendaTodo(t todo, when string) {
+	priority, _ := findColumn("priority")
+	name, _ := findColumn("name")
+	line := "  " + padText(when, 10, false) + padText(strconv.Itoa(t.id), 4, true) + "  "
+	marker := priorityMarker(t.priority) + " "
+	rest := t.name
+	if len(t.tags) > 0 {
+		rest += "  +" + strings.Join(t.tags, " +")
+	}
+	if t.context != "" {
+		rest += "  @" + t.context
+	}
+	if c.width > 0 {
+		rest = truncateText(rest, maxInt(c.width-displayWidth(line+marker), 10))
+	}
+	fmt.Println(line + c.todoStyle(t, priority) + marker + c.color["reset"] + c.todoStyle(t, name) + rest + c.color["reset"])
+}
+
+// calCell is the width of a day in the month grid, its number, the number
+// of todos due and ! when one of them is high priority
+const calCell = 6
+
+func calCmd(d *DbTable, f *flag.FlagSet) {
+	o := &calendarOptions{}
+	o.register(f)
+	month := f.String("m", "today", "A day in the month to show (e.g. 2026-11-01, +1m)")
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: todo cal [flags] [filter expression]")
+		fmt.Fprintln(f.Output(), "  e.g. todo cal -m +1m 'tags = work'")
+		f.PrintDefaults()
+	}
+	f.Parse(os.Args[2:])
+	day, err := parseDate(*month)
+	if err != nil {
+		fmt.Println("Error reading -m: ", err)
+		os.Exit(1)
+	}
+	first := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.Local)
+	next := first.AddDate(0, 1, 0)
+
+	counts := map[int]int{}
+	high := map[int]bool{}
+	overdue := map[int]bool{}
+	total, totalHigh := 0, 0
+	now := time.Now()
+	for _, t := range o.dueTodos(d, f, first, next) {
+		due, _ := t.dueTime()
+		counts[due.Day()]++
+		total++
+		if t.priority >= 3 {
+			high[due.Day()] = true
+			totalHigh++
+		}
+		if t.overdue(now) {
+			overdue[due.Day()] = true
+		}
+	}
+
+	c := newConsolePrint(d)
+	color := c.color
+	gridWidth := 7*(calCell+1) + 1
+	title := first.Format("January 2006")
+	fmt.Println(color["header"] + padText(title, (gridWidth+displayWidth(title))/2, true) + color["reset"])
+	var b strings.Builder
+	for i := 0; i < 7; i++ {
+		b.WriteString(" " + padText(((weekStart + time.Weekday(i)) % 7).String()[:3], calCell, false))
+	}
+	fmt.Println(color["header"] + strings.TrimRight(b.String(), " ") + color["reset"])
+
+	today := startOfDay(now)
+	offset := (int(first.Weekday()) - int(weekStart) + 7) % 7
+	for row := 0; row*7-offset < next.AddDate(0, 0, -1).Day(); row++ {
+		b.Reset()
+		previousToday := false
+		for column := 0; column < 7; column++ {
+			n := row*7 + column - offset + 1
+			date := first.AddDate(0, 0, n-1)
+			inMonth := n >= 1 && date.Month() == first.Month()
+			isToday := inMonth && date.Equal(today)
+			// Without colour today is marked with brackets in the gaps
+			// either side
+			switch {
+			case isToday && !colorOn():
+				b.WriteString("[")
+			case previousToday && !colorOn():
+				b.WriteString("]")
+			default:
+				b.WriteString(" ")
+			}
+			previousToday = isToday
+			if !inMonth {
+				b.WriteString(strings.Repeat(" ", calCell))
+				continue
+			}
+			count, marker := "", " "
+			if counts[n] > 0 {
+				count = strconv.Itoa(counts[n])
+			}
+			if high[n] {
+				marker = color["priority.3"] + "!" + color["reset"]
+			}
+			countStyle := color["text"]
+			if overdue[n] {
+				countStyle = color["overdue"]
+			}
+			number := padText(strconv.Itoa(n), 2, true)
+			if isToday && colorOn() {
+				number = selectedStyle + number + resetStyle
+			}
+			b.WriteString(number + countStyle + padText(count, 3, true) + color["reset"] + marker)
+		}
+		if previousToday && !colorOn() {
+			b.WriteString("]")
+		}
+		fmt.Println(strings.TrimRight(b.String(), " "))
+	}
+	fmt.Println()
+	fmt.Printf("%d due, %d high priority (!)", total, totalHigh)
+	if first.Equal(time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.Local)) {
+		if colorOn() {
+			fmt.Print(", today " + selectedStyle + "highlighted" + resetStyle)
+		} else {
+			fmt.Print(", [today]")
+		}
+	}
+	fmt.Println()
+}
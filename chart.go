@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// todo chart draws how the number of open and done todos changed over time
+// as an SVG file, for use outside the terminal. The history is rebuilt from
+// when each todo was created and completed, so deleted todos are not in it
+// and todos completed before completion times were recorded count as done
+// from the start.
+
+var chartKinds = []string{"burndown", "cfd"}
+
+// Sizes of the chart in pixels
+const (
+	chartWidth  = 800
+	chartHeight = 450
+	chartLeft   = 60
+	chartRight  = 140 // room for the legend
+	chartTop    = 50
+	chartBottom = 50
+)
+
+// chartPoint is the state of the todos at the end of a day
+type chartPoint struct {
+	day  time.Time
+	open int
+	done int
+}
+
+// chartSeries is a line or area of the chart
+type chartSeries struct {
+	name   string
+	color  string
+	values []float64
+	dashed bool
+}
+
+func chartCmd(d *DbTable, f *flag.FlagSet, config *Config) {
+	since := f.String("since", "-4w", "Start of the chart (e.g. -2w, 2026-10-01)")
+	until := f.String("until", "now", "End of the chart, a date means the end of that day")
+	out := f.String("out", "", "File to write, - for stdout (default <chart>.svg)")
+	tag := f.String("tag", "", "Only todos with this tag")
+	title := f.String("title", "", "Title of the chart (default from the chart and dates)")
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: todo chart burndown|cfd [@view] [flags] [filter expression]")
+		fmt.Fprintln(f.Output(), "  e.g. todo chart burndown -since -2w -tag release -out chart.svg")
+		f.PrintDefaults()
+	}
+	if len(os.Args) < 3 || !isChartKind(os.Args[2]) {
+		f.Usage()
+		os.Exit(1)
+	}
+	kind := os.Args[2]
+
+	// Only the filter of a view named with @ is used, its flags and the
+	// default view are for todo list
+	v, args, err := findView(os.Args[3:], config)
+	if err != nil {
+		fmt.Println("Error reading view: ", err)
+		os.Exit(1)
+	}
+	f.Parse(args)
+
+	from, err := parseDate(*since)
+	if err != nil {
+		fmt.Println("Error reading -since: ", err)
+		os.Exit(1)
+	}
+	to, err := parseDate(*until)
+	if err != nil {
+		fmt.Println("Error reading -until: ", err)
+		os.Exit(1)
+	}
+	if to.Equal(startOfDay(to)) {
+		to = to.AddDate(0, 0, 1)
+	}
+	from = startOfDay(from)
+	if !from.Before(to) {
+		fmt.Println("Error: -since must be before -until")
+		os.Exit(1)
+	}
+
+	filter, err := parseFilter(strings.Join(f.Args(), " "))
+	if err == nil && v != nil {
+		var saved *todoFilter
+		if saved, err = parseFilter(v.filter); err == nil {
+			filter.merge(saved)
+		}
+	}
+	if err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+	// Every matching todo is in the history, whatever the limit
+	filter.limit = 0
+	if *tag != "" {
+		filter.and("(' ' || tags || ' ') LIKE ? ESCAPE '\\'", "% "+escapeLike(*tag)+" %")
+	}
+	if err = d.checkFilter(filter); err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+
+	todos := d.queryTodos(filter, -1)
+	points := chartHistory(todos, from, to)
+	if *title == "" {
+		name := "Burndown"
+		if kind == "cfd" {
+			name = "Cumulative flow"
+		}
+		*title = fmt.Sprintf("%v, %v to %v", name, from.Format("2 Jan 2006"), to.Add(-time.Second).Format("2 Jan 2006"))
+	}
+	svg := renderChart(kind, *title, points)
+
+	if *out == "" {
+		*out = kind + ".svg"
+	}
+	if *out == "-" {
+		os.Stdout.Write(svg)
+		return
+	}
+	if err = os.WriteFile(*out, svg, 0644); err != nil {
+		fmt.Println("Error writing chart: ", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %v of %v to %v\n", kind, plural(len(todos), "todo"), *out)
+}
+
+func isChartKind(kind string) bool {
+	for _, k := range chartKinds {
+		if kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+// chartHistory counts the open and done todos at the end of each day from
+// from until to
+func chartHistory(todos []todo, from time.Time, to time.Time) []chartPoint {
+	var points []chartPoint
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		end := day.AddDate(0, 0, 1)
+		if end.After(to) {
+			end = to
+		}
+		p := chartPoint{day: day}
+		for _, t := range todos {
+			if t.created >= end.Unix() {
+				continue
+			}
+			if t.completed == 1 && t.completedAt < end.Unix() {
+				p.done++
+			} else {
+				p.open++
+			}
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// niceStep is a round step between axis ticks, 1, 2 or 5 times a power of
+// ten, giving at most ticks steps up to most
+func niceStep(most float64, ticks int) float64 {
+	if most <= 0 {
+		return 1
+	}
+	raw := most / float64(ticks)
+	power := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*power >= raw {
+			return math.Max(m*power, 1)
+		}
+	}
+	return 10 * power
+}
+
+func renderChart(kind string, title string, points []chartPoint) []byte {
+	n := len(points)
+	var series []chartSeries
+	if kind == "burndown" {
+		open := make([]float64, n)
+		ideal := make([]float64, n)
+		for i, p := range points {
+			open[i] = float64(p.open)
+			if n > 1 {
+				ideal[i] = float64(points[0].open) * float64(n-1-i) / float64(n-1)
+			}
+		}
+		series = []chartSeries{
+			{name: "Open", color: "#d62728", values: open},
+			{name: "Ideal", color: "#7f7f7f", values: ideal, dashed: true},
+		}
+	} else {
+		// Stacked, so open is drawn on top of done
+		done := make([]float64, n)
+		total := make([]float64, n)
+		for i, p := range points {
+			done[i] = float64(p.done)
+			total[i] = float64(p.done + p.open)
+		}
+		series = []chartSeries{
+			{name: "Open", color: "#1f77b4", values: total},
+			{name: "Done", color: "#2ca02c", values: done},
+		}
+	}
+
+	most := 0.0
+	for _, s := range series {
+		for _, v := range s.values {
+			most = math.Max(most, v)
+		}
+	}
+	step := niceStep(most, 5)
+	top := math.Max(math.Ceil(most/step)*step, step)
+
+	plotWidth := float64(chartWidth - chartLeft - chartRight)
+	plotHeight := float64(chartHeight - chartTop - chartBottom)
+	x := func(i int) float64 {
+		if n <= 1 {
+			return chartLeft + plotWidth/2
+		}
+		return chartLeft + plotWidth*float64(i)/float64(n-1)
+	}
+	y := func(v float64) float64 {
+		return chartTop + plotHeight*(1-v/top)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	fmt.Fprintf(&b, `<text x="%d" y="28" font-size="16" font-weight="bold">%v</text>`+"\n", chartLeft, html.EscapeString(title))
+
+	// Grid lines and the y axis labels
+	for v := 0.0; v <= top; v += step {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#e0e0e0"/>`+"\n", chartLeft, y(v), chartLeft+plotWidth, y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%v</text>`+"\n", chartLeft-8, y(v), v)
+	}
+	// Dates along the x axis, at most 8 of them
+	every := maxInt((n+7)/8, 1)
+	for i := 0; i < n; i += every {
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#333333"/>`+"\n", x(i), chartTop+plotHeight, x(i), chartTop+plotHeight+5)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle">%v</text>`+"\n", x(i), chartTop+plotHeight+20, points[i].day.Format("2 Jan"))
+	}
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%.1f" stroke="#333333"/>`+"\n", chartLeft, chartTop, chartLeft, chartTop+plotHeight)
+	fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#333333"/>`+"\n", chartLeft, chartTop+plotHeight, chartLeft+plotWidth, chartTop+plotHeight)
+	fmt.Fprintf(&b, `<text transform="translate(16 %.1f) rotate(-90)" text-anchor="middle">Todos</text>`+"\n", chartTop+plotHeight/2)
+
+	for _, s := range series {
+		var line []string
+		for i, v := range s.values {
+			line = append(line, fmt.Sprintf("%.1f,%.1f", x(i), y(v)))
+		}
+		if kind == "cfd" {
+			// An area down to the x axis, done is drawn over the bottom of
+			// open
+			area := append(append([]string{fmt.Sprintf("%.1f,%.1f", x(0), y(0))}, line...), fmt.Sprintf("%.1f,%.1f", x(n-1), y(0)))
+			fmt.Fprintf(&b, `<polygon points="%v" fill="%v" fill-opacity="0.8" stroke="%v"/>`+"\n", strings.Join(area, " "), s.color, s.color)
+			continue
+		}
+		dash := ""
+		if s.dashed {
+			dash = ` stroke-dasharray="6 4"`
+		}
+		fmt.Fprintf(&b, `<polyline points="%v" fill="none" stroke="%v" stroke-width="2"%v/>`+"\n", strings.Join(line, " "), s.color, dash)
+	}
+
+	// Legend
+	for i, s := range series {
+		ly := chartTop + 10 + 22*i
+		lx := chartWidth - chartRight + 20
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="14" height="14" fill="%v"/>`+"\n", lx, ly-7, s.color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" dominant-baseline="middle">%v</text>`+"\n", lx+20, ly, html.EscapeString(s.name))
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
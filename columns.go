@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tableColumn is a column the table can show. Fixed columns (flex 0) are as
+// wide as their widest value between min and max, flexible columns share the
+// width left over in proportion to flex.
+type tableColumn struct {
+	name    string
+	title   string
+	min     int
+	max     int // 0 for no limit
+	flex    int
+	numeric bool // right aligned
+	wrap    bool // long values continue on the next line, others are cut
+	value   func(t todo) string
+}
+
+// tableColumns are the columns -columns can choose from. Widths can be
+// changed with todo config set column.<name>.<min|max|flex> <n>
+var tableColumns = []tableColumn{
+	{"id", "ID", 2, 0, 0, true, false, func(t todo) string { return strconv.Itoa(t.id) }},
+	{"name", "Name", 20, 0, 1, false, true, func(t todo) string { return t.name }},
+	{"content", "Content", 10, 0, 3, false, true, func(t todo) string { return t.content }},
+	{"priority", "Priority", 1, 0, 0, true, false, func(t todo) string { return strconv.Itoa(int(t.priority)) }},
+	{"completed", "Completed", 1, 0, 0, false, false, func(t todo) string {
+		if t.completed == 1 {
+			return "✓"
+		}
+		return "✗"
+	}},
+	{"due", "Due", 3, 16, 0, false, false, func(t todo) string {
+		if due, hasDue := t.dueTime(); hasDue {
+			return formatDate(due)
+		}
+		return ""
+	}},
+	{"created", "Created", 7, 10, 0, false, false, func(t todo) string {
+		if t.created == 0 {
+			return ""
+		}
+		return time.Unix(t.created, 0).Format("2006-01-02")
+	}},
+	{"tags", "Tags", 4, 30, 1, false, true, func(t todo) string { return joinTags(t.tags) }},
+	{"context", "Context", 7, 20, 0, false, false, func(t todo) string { return t.context }},
+}
+
+// defaultColumns are shown unless -columns or the columns config key says
+// otherwise
+const defaultColumns = "id,name,content,priority,completed"
+
+const (
+	configColumns      = "columns"
+	columnConfigPrefix = "column."
+)
+
+func findColumn(name string) (tableColumn, bool) {
+	for _, c := range tableColumns {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return tableColumn{}, false
+}
+
+func columnNames() string {
+	var names []string
+	for _, c := range tableColumns {
+		names = append(names, c.name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// parseColumns reads a comma separated list of column names
+func parseColumns(list string) ([]tableColumn, error) {
+	var columns []tableColumn
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		c, found := findColumn(name)
+		if !found {
+			return nil, fmt.Errorf("unknown column %q, expected one of %v", name, columnNames())
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// loadColumns reads a column list, falling back to the config and then the
+// defaults, and applies the widths set in the config
+func loadColumns(list string, config *Config) ([]tableColumn, error) {
+	if list == "" {
+		list = config.GetValue(configColumns)
+	}
+	if list == "" {
+		list = defaultColumns
+	}
+	columns, err := parseColumns(list)
+	if err != nil {
+		return nil, err
+	}
+	for i := range columns {
+		c := &columns[i]
+		for _, setting := range []struct {
+			key   string
+			value *int
+		}{{"min", &c.min}, {"max", &c.max}, {"flex", &c.flex}} {
+			value := config.GetValue(columnConfigPrefix + c.name + "." + setting.key)
+			if value == "" {
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid %v%v.%v in config: %q is not a whole number", columnConfigPrefix, c.name, setting.key, value)
+			}
+			*setting.value = n
+		}
+		if c.min < 1 {
+			c.min = 1
+		}
+	}
+	return columns, nil
+}
+
+// validColumnKey checks a column.<name>.<setting> config value before it is
+// saved
+func validColumnKey(key string, value string) error {
+	parts := strings.Split(strings.TrimPrefix(key, columnConfigPrefix), ".")
+	if len(parts) != 2 {
+		return fmt.Errorf("expected %v<column>.<min|max|flex>", columnConfigPrefix)
+	}
+	if _, found := findColumn(parts[0]); !found {
+		return fmt.Errorf("unknown column %q, expected one of %v", parts[0], columnNames())
+	}
+	switch parts[1] {
+	case "min", "max", "flex":
+	default:
+		return fmt.Errorf("unknown setting %q, expected min, max or flex", parts[1])
+	}
+	if value == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(value); err != nil || n < 0 {
+		return fmt.Errorf("%q is not a whole number", value)
+	}
+	return nil
+}
@@ -45,6 +45,12 @@ func (c *Config) GetConfig() map[string]interface{} {
 	return c.config
 }
 
+// GetValue returns a string value from the user config or "" if it is unset
+func (c *Config) GetValue(key string) string {
+	value, _ := c.config[key].(string)
+	return value
+}
+
 func (c *Config) GetDbName() string {
 	return c.DbName
 }
@@ -116,7 +122,14 @@ func ReadConfig(filePath string) (*Config, error) {
 }
 
 func (c *Config) WriteConfig() error {
-	jsonStr, err := json.Marshal(c.config)
+	// Write the whole structure so that ReadConfig finds the same fields
+	// it expects rather than only the user config map
+	jsonStr, err := json.MarshalIndent(map[string]interface{}{
+		"ConfigPath": c.ConfigPath,
+		"DbName":     c.DbName,
+		"TableName":  c.TableName,
+		"config":     c.config,
+	}, "", "\t")
 	if err != nil {
 		return err
 	}
@@ -2,190 +2,368 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	tsize "github.com/kopoli/go-terminal-size"
+	"golang.org/x/term"
 )
 
 type ConsolePrint struct {
-	width          int
-	height         int
-	idWidth        int
-	nameWidth      int
-	contentWidth   int
-	priorityWidth  int
-	completedWidth int
-	color          map[string]string
-	prettyPrint    bool
+	width   int // 0 for no limit
+	height  int
+	columns []tableColumn
+	color   styles // from the theme, see setupColor
+	mode    string // one of layoutModes
+	context string // shown in the header when set
 }
 
-func consoleSize() (int, int, bool) {
-	size, err := tsize.GetSize()
-	if err != nil {
-		panic(err)
+// layoutModes are the ways todos can be shown, chosen with --layout or the
+// layout config key. auto picks one from the width of the terminal.
+var layoutModes = []string{"auto", "table", "compact", "cards"}
+
+const configLayout = "layout"
+
+// Terminals narrower than these get the compact and card layouts
+const (
+	tableMinWidth   = 80
+	compactMinWidth = 40
+)
+
+func isLayoutMode(mode string) bool {
+	for _, m := range layoutModes {
+		if mode == m {
+			return true
+		}
 	}
-	// fmt.Println("Width: ", size.Width, "Height: ", size.Height)
-	correctSize := true
-	if size.Width < 80 || size.Height < 10 {
-		fmt.Println("Terminal size too small for nice formatting")
-		correctSize = false
+	return false
+}
+
+// autoLayout chooses a layout for the width. Output that is not going to a
+// terminal is always compact so that there is a line per todo.
+func autoLayout(width int, interactive bool) string {
+	switch {
+	case !interactive:
+		return "compact"
+	case width < compactMinWidth:
+		return "cards"
+	case width < tableMinWidth:
+		return "compact"
 	}
-	return size.Width, size.Height, correctSize
+	return "table"
 }
 
-func NewConsolePrint() *ConsolePrint {
-	w, h, correctSize := consoleSize()
-	wId := 5
-	wPriority := 9
-	wCompleted := 10
+const configWidth = "width"
+
+// defaultWidth is the width of output that is not going to a terminal when
+// COLUMNS is not set, 0 for no limit. Set from the width config key.
+var defaultWidth = 0
 
-	remainingWidth := w - wId - wPriority - wCompleted
-	wName := remainingWidth / 4
-	if wName < 20 {
-		wName = 20
+// setupWidth reads the width config key
+func setupWidth(config *Config) {
+	if value := config.GetValue(configWidth); value != "" {
+		width, err := strconv.Atoi(value)
+		if err != nil || width < 0 {
+			fmt.Fprintf(os.Stderr, "Ignoring invalid %v in config: %q is not a whole number\n", configWidth, value)
+			return
+		}
+		defaultWidth = width
 	}
+}
+
+type terminalSize struct {
+	width       int
+	height      int
+	interactive bool
+}
+
+// terminal is the size found by the first call to consoleSize, which is
+// kept as stdout may be redirected to a pager afterwards
+var terminal *terminalSize
 
-	totalWidth := wId + wName + wPriority + wCompleted + 5 // 5 is for the 4 spaces and the border
-	wContent := w - totalWidth
+// consoleSize returns the size of the terminal and whether stdout is one.
+// Otherwise the width is taken from COLUMNS, then the width config key.
+func consoleSize() (int, int, bool) {
+	if terminal == nil {
+		terminal = &terminalSize{defaultWidth, 0, false}
+		size, err := tsize.GetSize()
+		if err == nil && term.IsTerminal(int(os.Stdout.Fd())) {
+			terminal.width, terminal.height, terminal.interactive = size.Width, size.Height, true
+		} else if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+			terminal.width = columns
+		}
+	}
+	return terminal.width, terminal.height, terminal.interactive
+}
+
+func NewConsolePrint() *ConsolePrint {
+	w, h, interactive := consoleSize()
+	columns, _ := parseColumns(defaultColumns)
 
 	return &ConsolePrint{
-		width:  w,
-		height: h,
-		color: map[string]string{
-			"border":    "\033[34m",
-			"error":     "\033[31m",
-			"warning":   "\033[33m",
-			"success":   "\033[32m",
-			"null":      "\033[37m",
-			"white":     "\033[37m",
-			"bold":      "\033[1m",
-			"italic":    "\033[3m",
-			"underline": "\033[4m",
-			"normal":    "\033[0m",
-		},
-		idWidth:        wId,
-		priorityWidth:  wPriority,
-		completedWidth: wCompleted,
-		nameWidth:      wName,
-		contentWidth:   wContent,
-		prettyPrint:    correctSize,
-	}
-}
-
-func (c ConsolePrint) printHeader() {
-	c.printHeaderDivider()
-	// Id | Priority | Completed are fixed width elements. Name and content are variable width
-	// Name and content should wrap to next line if they are too long
-	// Print the column names for the table
-	fmt.Print("| ",
-		c.color["white"],
-		c.color["bold"],
-		"ID", strings.Repeat(" ", c.idWidth),
-		"Name", strings.Repeat(" ", c.nameWidth-4),
-		"Content", strings.Repeat(" ", c.contentWidth-7),
-		"Priority", strings.Repeat(" ", c.priorityWidth-8),
-		"Completed",
-		c.color["normal"],
-		c.color["border"],
-		" |\n")
-	c.printHeaderDivider()
-}
-
-func (c ConsolePrint) printHeaderDivider() {
-	fmt.Println(c.color["border"], strings.Repeat("=", c.width-2))
-}
-
-func (c ConsolePrint) printFooter() {
-	fmt.Println(c.color["border"], strings.Repeat("=", c.width-2))
-}
-
-func (c ConsolePrint) printDivider() {
-	fmt.Println(c.color["border"], strings.Repeat("-", c.width-2))
-}
-
-func getLineContent(content string, width int, lineNumber int) string {
-	var nameString string
-	if lineNumber <= width && len(content) > width {
-		if width > len(content[width*lineNumber:]) {
-			nameString = content[lineNumber*width:]
-		} else {
-			nameString = content[lineNumber*width : (lineNumber+1)*width]
+		width:   w,
+		height:  h,
+		columns: columns,
+		color:   currentStyles,
+		mode:    autoLayout(w, interactive),
+	}
+}
+
+// newConsolePrint returns a ConsolePrint that shows the context d is
+// scoped to
+func newConsolePrint(d *DbTable) *ConsolePrint {
+	c := NewConsolePrint()
+	if d.context != nil {
+		c.context = d.context.name
+	}
+	return c
+}
+
+// layout works out the width of each column. Fixed columns fit their
+// widest value, then what is left of the terminal width is shared between
+// the flexible columns in proportion to their flex.
+func (c ConsolePrint) layout(todos []todo) []int {
+	widths := make([]int, len(c.columns))
+	used := c.overhead()
+	totalFlex := 0
+	for i, col := range c.columns {
+		widths[i] = col.min
+		// Without a width limit every column fits its values
+		if col.flex == 0 || c.width <= 0 {
+			widths[i] = maxInt(widths[i], displayWidth(col.title))
+			for _, t := range todos {
+				widths[i] = maxInt(widths[i], displayWidth(truncateText(col.value(t), math.MaxInt32)))
+			}
+			if col.max > 0 && widths[i] > col.max {
+				widths[i] = maxInt(col.max, col.min)
+			}
 		}
-	} else if lineNumber == 0 {
-		nameString = content
-	} else {
-		nameString = ""
+		totalFlex += col.flex
+		used += widths[i]
 	}
-	if nameString != "" && nameString[0] == ' ' {
-		nameString = nameString[1:]
+
+	remaining := c.width - used
+	for remaining > 0 {
+		growable := 0
+		for i, col := range c.columns {
+			if col.flex > 0 && (col.max == 0 || widths[i] < col.max) {
+				growable += col.flex
+			}
+		}
+		if growable == 0 {
+			break
+		}
+		given := 0
+		for i, col := range c.columns {
+			if col.flex == 0 || (col.max > 0 && widths[i] >= col.max) {
+				continue
+			}
+			extra := maxInt(remaining*col.flex/growable, 1)
+			if col.max > 0 && widths[i]+extra > col.max {
+				extra = col.max - widths[i]
+			}
+			if given+extra > remaining {
+				extra = remaining - given
+			}
+			widths[i] += extra
+			given += extra
+		}
+		if given == 0 {
+			break
+		}
+		remaining -= given
 	}
-	return nameString
+	return widths
 }
 
-func (c ConsolePrint) printTodo(t todo) {
-	var check string
-	if t.completed == 1 {
-		check = "\u2713"
-	} else {
-		check = "\u2717"
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
 	}
+	return b
+}
 
-	contentWraps := len(t.content) / c.contentWidth
-	nameWraps := len(t.name) / c.nameWidth
+// overhead is the width taken up by borders and the space between columns
+func (c ConsolePrint) overhead() int {
+	if c.mode == "compact" {
+		return 2 * (len(c.columns) - 1)
+	}
+	return 4 + len(c.columns) - 1
+}
 
-	var nWraps int
-	if nameWraps > contentWraps {
-		nWraps = nameWraps
-	} else {
-		nWraps = contentWraps
+func tableWidth(widths []int) int {
+	total := 4 + len(widths) - 1
+	for _, w := range widths {
+		total += w
 	}
+	return total
+}
 
-	for i := 0; i <= nWraps; i++ {
+// printContextHeader names the context the output is limited to, if there is one
+func (c ConsolePrint) printContextHeader() {
+	if c.context != "" {
+		fmt.Println(c.color["context"] + "Context: " + c.context + c.color["reset"])
+	}
+}
+
+func (c ConsolePrint) printHeader(widths []int) {
+	c.printContextHeader()
+	c.printHeaderDivider(widths)
+	cells := make([]string, len(c.columns))
+	for i, col := range c.columns {
+		cells[i] = padText(truncateText(col.title, widths[i]), widths[i], col.numeric)
+	}
+	c.printRow(cells, func(int) string { return c.color["header"] })
+	c.printHeaderDivider(widths)
+}
+
+func (c ConsolePrint) printHeaderDivider(widths []int) {
+	fmt.Println(c.color["border"], strings.Repeat("=", tableWidth(widths)-2))
+}
+
+func (c ConsolePrint) printDivider(widths []int) {
+	fmt.Println(c.color["border"], strings.Repeat("-", tableWidth(widths)-2))
+}
 
-		nameString := getLineContent(t.name, c.nameWidth, i)
-		contentString := getLineContent(t.content, c.contentWidth, i)
+// printRow prints one line of the table with each cell in its own style
+func (c ConsolePrint) printRow(cells []string, style func(column int) string) {
+	var b strings.Builder
+	b.WriteString(c.color["border"] + "| " + c.color["reset"])
+	for i, cell := range cells {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(style(i) + cell + c.color["reset"])
+	}
+	b.WriteString(c.color["border"] + " |" + c.color["reset"])
+	fmt.Println(b.String())
+}
 
-		if i == 0 {
-			fmt.Print("| ",
-				c.color["white"],
-				t.id, strings.Repeat(" ", c.idWidth-len(strconv.FormatInt(int64(t.id), 10))+2),
-				nameString, strings.Repeat(" ", c.nameWidth-len(nameString)),
-				contentString, strings.Repeat(" ", c.contentWidth-len(contentString)),
-				strings.Repeat(" ", len("priority")),
-				t.priority, strings.Repeat(" ", c.priorityWidth-len(strconv.FormatInt(int64(t.priority), 10))),
-				check,
-				c.color["border"],
-				" |")
+// todoStyle is the style of a cell of t. Completed and overdue todos are
+// styled as a whole, otherwise the priority column shows the priority.
+func (c ConsolePrint) todoStyle(t todo, col tableColumn) string {
+	switch {
+	case t.completed == 1:
+		return c.color["completed"]
+	case t.overdue(time.Now()):
+		return c.color["overdue"]
+	case col.name == "priority":
+		return c.color[fmt.Sprintf("priority.%d", t.priority)]
+	}
+	return c.color["text"]
+}
+
+func (c ConsolePrint) printTodo(t todo, widths []int) {
+	// Each column's value split into the lines it takes up
+	cells := make([][]string, len(c.columns))
+	rows := 1
+	for i, col := range c.columns {
+		value := col.value(t)
+		if col.wrap {
+			cells[i] = wrapText(value, widths[i])
 		} else {
-			fmt.Print("| ",
-				c.color["white"],
-				strings.Repeat(" ", c.idWidth+2),
-				nameString, strings.Repeat(" ", c.nameWidth-len(nameString)),
-				contentString, strings.Repeat(" ", c.contentWidth-len(contentString)),
-				strings.Repeat(" ", c.priorityWidth),
-				strings.Repeat(" ", c.completedWidth-1),
-				c.color["border"],
-				" |")
+			cells[i] = []string{truncateText(value, widths[i])}
+		}
+		rows = maxInt(rows, len(cells[i]))
+	}
+
+	style := func(i int) string { return c.todoStyle(t, c.columns[i]) }
+	for row := 0; row < rows; row++ {
+		line := make([]string, len(c.columns))
+		for i, col := range c.columns {
+			value := ""
+			if row < len(cells[i]) {
+				value = cells[i][row]
+			}
+			line[i] = padText(value, widths[i], col.numeric)
 		}
+		c.printRow(line, style)
 	}
 }
 
 func (c ConsolePrint) resetColor() {
-	fmt.Println(c.color["null"])
+	fmt.Println(c.color["reset"])
 }
 
-func (c ConsolePrint) printTodos(todos []todo) {
-	if !c.prettyPrint {
-		for _, t := range todos {
-			fmt.Println(t)
+// printCompact prints a line per todo in aligned columns without borders,
+// shortening values that do not fit, so that output can also be read by
+// grep, cut and the like
+func (c ConsolePrint) printCompact(todos []todo) {
+	c.printContextHeader()
+	widths := c.layout(todos)
+	line := func(value func(col tableColumn) string, style func(col tableColumn) string) {
+		var b strings.Builder
+		for i, col := range c.columns {
+			cell := padText(truncateText(value(col), widths[i]), widths[i], col.numeric)
+			if i == len(c.columns)-1 {
+				cell = strings.TrimRight(cell, " ")
+			} else {
+				cell += "  "
+			}
+			b.WriteString(style(col) + cell + c.color["reset"])
+		}
+		fmt.Println(b.String())
+	}
+	line(func(col tableColumn) string { return col.title }, func(tableColumn) string { return c.color["header"] })
+	for _, t := range todos {
+		line(func(col tableColumn) string { return col.value(t) }, func(col tableColumn) string { return c.todoStyle(t, col) })
+	}
+}
+
+// printCards prints each todo as a block of label: value lines, the
+// narrowest layout
+func (c ConsolePrint) printCards(todos []todo) {
+	c.printContextHeader()
+	labelWidth := 0
+	for _, col := range c.columns {
+		labelWidth = maxInt(labelWidth, displayWidth(col.title))
+	}
+	valueWidth := math.MaxInt32
+	if c.width > 0 {
+		valueWidth = maxInt(c.width-labelWidth-4, 10)
+	}
+	for _, t := range todos {
+		fmt.Println(c.color["border"] + strings.Repeat("-", maxInt(minInt(c.width, 40), 10)) + c.color["reset"])
+		for _, col := range c.columns {
+			value := col.value(t)
+			if value == "" {
+				continue
+			}
+			for j, line := range wrapText(value, valueWidth) {
+				label := ""
+				if j == 0 {
+					label = col.title + ":"
+				}
+				fmt.Println(c.color["header"]+padText(label, labelWidth+1, false)+c.color["reset"], c.todoStyle(t, col)+line+c.color["reset"])
+			}
 		}
+	}
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (c ConsolePrint) printTodos(todos []todo) {
+	switch c.mode {
+	case "compact":
+		c.printCompact(todos)
+		return
+	case "cards":
+		c.printCards(todos)
 		return
 	}
-	c.printHeader()
+	widths := c.layout(todos)
+	c.printHeader(widths)
 	for _, t := range todos {
-		c.printTodo(t)
-		c.printDivider()
+		c.printTodo(t, widths)
+		c.printDivider(widths)
 	}
 	c.resetColor()
 }
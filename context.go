@@ -0,0 +1,275 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A context scopes every command to a filter, e.g. while working on one
+// project, and gives new todos default attributes. They are kept in the user
+// config as
+//
+//	"contexts": {"work": {"filter": "name ~ \"work\"", "defaults": {"priority": "2", "tags": "acme", "context": "office"}}}
+//	"context": "work"
+const (
+	configContexts = "contexts"
+	configContext  = "context"
+)
+
+type todoContext struct {
+	name     string
+	filter   string
+	defaults map[string]string
+	compiled *todoFilter
+}
+
+// contextDefaults are the attributes a context can set on new todos
+var contextDefaults = []string{"priority", "tags", "context"}
+
+func getContexts(config *Config) map[string]*todoContext {
+	contexts := map[string]*todoContext{}
+	saved, _ := config.GetConfig()[configContexts].(map[string]interface{})
+	for name, value := range saved {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		c := &todoContext{name: name, defaults: map[string]string{}}
+		c.filter, _ = fields["filter"].(string)
+		defaults, _ := fields["defaults"].(map[string]interface{})
+		for key, value := range defaults {
+			if s, ok := value.(string); ok {
+				c.defaults[key] = s
+			}
+		}
+		contexts[name] = c
+	}
+	return contexts
+}
+
+func writeContexts(config *Config, contexts map[string]*todoContext) error {
+	saved := map[string]interface{}{}
+	for name, c := range contexts {
+		saved[name] = map[string]interface{}{"filter": c.filter, "defaults": c.defaults}
+	}
+	config.GetConfig()[configContexts] = saved
+	return config.WriteConfig()
+}
+
+// loadContext returns the active context, or nil when there is none
+func loadContext(config *Config) (*todoContext, error) {
+	name := config.GetValue(configContext)
+	if name == "" {
+		return nil, nil
+	}
+	c, found := getContexts(config)[name]
+	if !found {
+		return nil, fmt.Errorf("active context %q is not defined", name)
+	}
+	var err error
+	if c.compiled, err = parseFilter(c.filter); err != nil {
+		return nil, fmt.Errorf("context %q: %v", name, err)
+	}
+	return c, nil
+}
+
+// defaultPriority returns the priority for new todos in the context, or
+// fallback if the context does not set one
+func (c *todoContext) defaultPriority(fallback int) int {
+	if c == nil {
+		return fallback
+	}
+	if p, err := strconv.Atoi(c.defaults["priority"]); err == nil {
+		return p
+	}
+	return fallback
+}
+
+// fillDefaults adds the context's default tags to t and sets its default
+// todo context if t does not have one
+func (c *todoContext) fillDefaults(t todo) todo {
+	if c == nil {
+		return t
+	}
+	if tags := splitTags(c.defaults["tags"]); len(tags) > 0 {
+		t.tags = splitTags(joinTags(append(append([]string{}, t.tags...), tags...)))
+	}
+	if t.context == "" {
+		t.context = c.defaults["context"]
+	}
+	return t
+}
+
+// checkInContext stops commands that take an id from reaching a todo outside
+// the active context
+func checkInContext(d *DbTable, ids ...int) {
+	if d.context == nil {
+		return
+	}
+	for _, id := range ids {
+		if id == 0 {
+			continue
+		}
+		found, err := d.inScope(id)
+		if err != nil {
+			fmt.Printf("Error in context %v: %v\n", d.context.name, err)
+			os.Exit(1)
+		}
+		if !found {
+			fmt.Printf("No todo %d in context %v, clear it with todo context none\n", id, d.context.name)
+			os.Exit(1)
+		}
+	}
+}
+
+func contextCmd(f *flag.FlagSet, config *Config) {
+	usage := `Usage:
+  todo context define <name> [-p <priority>] [-tags <tags>] [-context <context>] <filter expression>
+  todo context set <name>
+  todo context none
+  todo context show
+  todo context list
+  todo context del <name>`
+	args := os.Args[2:]
+	if len(args) < 1 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+
+	contexts := getContexts(config)
+	active := config.GetValue(configContext)
+	switch args[0] {
+	case "define":
+		if len(args) < 2 || !viewName.MatchString(args[1]) || args[1] == "none" {
+			fmt.Println("A context needs a name made of letters, numbers, - and _")
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		var priority int
+		var tags, place string
+		f.IntVar(&priority, "p", 0, "Priority of new todos in the context (1 <low> - 3 <high>)")
+		f.StringVar(&tags, "tags", "", "Tags added to new todos in the context, separated by spaces or commas")
+		f.StringVar(&place, "context", "", "Context of new todos in the context that do not give one, e.g. office")
+		f.Parse(args[2:])
+		filter := strings.Join(f.Args(), " ")
+		compiled, err := parseFilter(filter)
+		if err != nil {
+			fmt.Println("Error in filter: ", err)
+			os.Exit(1)
+		}
+		if compiled.limit != 0 || len(compiled.sort) != 0 {
+			fmt.Println("A context filter cannot have sort: or limit: clauses")
+			os.Exit(1)
+		}
+		c := &todoContext{name: args[1], filter: filter, defaults: map[string]string{}}
+		if priority != 0 {
+			c.defaults["priority"] = strconv.Itoa(priority)
+		}
+		defaultTags, err := parseTagList(tags)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			os.Exit(1)
+		}
+		if len(defaultTags) > 0 {
+			c.defaults["tags"] = joinTags(defaultTags)
+		}
+		if place, err = parseContextName(place); err != nil {
+			fmt.Println("Error: ", err)
+			os.Exit(1)
+		}
+		if place != "" {
+			c.defaults["context"] = place
+		}
+		contexts[c.name] = c
+		if err = writeContexts(config, contexts); err != nil {
+			fmt.Println("Error saving context: ", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Defined context %v, switch to it with todo context set %v\n", c.name, c.name)
+	case "set":
+		if len(args) < 2 {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		if _, found := contexts[args[1]]; !found {
+			fmt.Printf("No context named %q, define it with todo context define\n", args[1])
+			os.Exit(1)
+		}
+		if err := config.Set(configContext, args[1]); err != nil {
+			fmt.Println("Error setting context: ", err)
+			os.Exit(1)
+		}
+		fmt.Println("Context: ", args[1])
+	case "none":
+		if err := config.Delete(configContext); err != nil {
+			fmt.Println("Error clearing context: ", err)
+			os.Exit(1)
+		}
+		fmt.Println("Cleared context")
+	case "show":
+		if active == "" {
+			fmt.Println("No context")
+			return
+		}
+		printContext(contexts[active], active)
+	case "list":
+		if len(contexts) == 0 {
+			fmt.Println("No contexts defined")
+			return
+		}
+		var names []string
+		for name := range contexts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			printContext(contexts[name], active)
+		}
+	case "del":
+		if len(args) < 2 {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		if _, found := contexts[args[1]]; !found {
+			fmt.Printf("No context named %q\n", args[1])
+			os.Exit(1)
+		}
+		delete(contexts, args[1])
+		if err := writeContexts(config, contexts); err != nil {
+			fmt.Println("Error deleting context: ", err)
+			os.Exit(1)
+		}
+		if active == args[1] {
+			if err := config.Delete(configContext); err != nil {
+				fmt.Println("Error clearing context: ", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Println("Deleted context: ", args[1])
+	default:
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+}
+
+func printContext(c *todoContext, active string) {
+	if c == nil {
+		fmt.Printf("  %v (not defined, clear it with todo context none)\n", active)
+		return
+	}
+	marker := " "
+	if c.name == active {
+		marker = "*"
+	}
+	fmt.Printf("%v %-15v '%v'", marker, c.name, c.filter)
+	for _, key := range contextDefaults {
+		if value, found := c.defaults[key]; found {
+			fmt.Printf(" %v=%v", key, value)
+		}
+	}
+	fmt.Println()
+}
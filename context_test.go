@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestContextFillDefaults(t *testing.T) {
+	c := &todoContext{name: "work", defaults: map[string]string{"tags": "acme ops", "context": "office"}}
+	tests := []struct {
+		t           todo
+		wantTags    []string
+		wantContext string
+	}{
+		{todo{name: "plain"}, []string{"acme", "ops"}, "office"},
+		{todo{name: "tagged", tags: []string{"deploy", "acme"}}, []string{"acme", "deploy", "ops"}, "office"},
+		{todo{name: "elsewhere", context: "home"}, []string{"acme", "ops"}, "home"},
+	}
+	for _, test := range tests {
+		got := c.fillDefaults(test.t)
+		if !reflect.DeepEqual(got.tags, test.wantTags) || got.context != test.wantContext {
+			t.Errorf("fillDefaults(%q) = %q @%v, want %q @%v", test.t.name, got.tags, got.context, test.wantTags, test.wantContext)
+		}
+	}
+
+	var none *todoContext
+	if got := none.fillDefaults(todo{name: "x", tags: []string{"a"}}); !reflect.DeepEqual(got.tags, []string{"a"}) || got.context != "" {
+		t.Errorf("fillDefaults without a context = %q @%v", got.tags, got.context)
+	}
+	priorityOnly := &todoContext{name: "p", defaults: map[string]string{"priority": "2"}}
+	if got := priorityOnly.fillDefaults(todo{name: "x"}); got.tags != nil || got.context != "" {
+		t.Errorf("fillDefaults with only a priority = %q @%v", got.tags, got.context)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// Environment variables the encryption key is read from, checked in order
+const (
+	envKey        = "TODO_KEY"        // derived key as printed by 'todo unlock'
+	envKeyFile    = "TODO_KEYFILE"    // path to a file holding the secret
+	envPassphrase = "TODO_PASSPHRASE" // the passphrase itself
+)
+
+// Config keys holding the encryption parameters. An empty salt means the
+// database is not encrypted. They are only changed by todo encrypt and
+// decrypt, config set and del refuse keys with the prefix.
+const (
+	encryptionConfigPrefix = "encryption."
+	configSalt             = encryptionConfigPrefix + "salt"
+	configCheck            = encryptionConfigPrefix + "check"
+)
+
+// encPrefix marks an encrypted value so that plain text and encrypted rows
+// can be told apart, e.g. after an interrupted encrypt.
+const encPrefix = "enc:v1:"
+
+// checkValue is encrypted with the key and kept in the config so that a
+// wrong key is reported before any row is read.
+const checkValue = "todo"
+
+var errWrongKey = errors.New("incorrect passphrase or key file")
+
+type todoCipher struct {
+	aead cipher.AEAD
+	key  []byte
+}
+
+func newTodoCipher(key []byte) (*todoCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &todoCipher{aead: aead, key: key}, nil
+}
+
+// deriveKey stretches a passphrase or key file contents into an AES-256 key
+func deriveKey(secret []byte, salt []byte) ([]byte, error) {
+	return scrypt.Key(secret, salt, 1<<15, 8, 1, 32)
+}
+
+// encrypt seals value, binding it to the column it is stored in so values
+// cannot be swapped between columns.
+func (c *todoCipher) encrypt(column string, value string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(value), []byte(column))
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value sealed by encrypt. Values without the prefix are
+// returned as they are.
+func (c *todoCipher) decrypt(column string, value string) (string, error) {
+	if !strings.HasPrefix(value, encPrefix) {
+		return value, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, []byte(column))
+	if err != nil {
+		return "", errWrongKey
+	}
+	return string(plain), nil
+}
+
+func (c *todoCipher) encryptTodo(t todo) (todo, error) {
+	var err error
+	if t.name, err = c.encrypt("name", t.name); err != nil {
+		return t, err
+	}
+	t.content, err = c.encrypt("content", t.content)
+	return t, err
+}
+
+func (c *todoCipher) decryptTodo(t todo) (todo, error) {
+	var err error
+	if t.name, err = c.decrypt("name", t.name); err != nil {
+		return t, err
+	}
+	t.content, err = c.decrypt("content", t.content)
+	return t, err
+}
+
+func encryptionEnabled(config *Config) bool {
+	return config.GetValue(configSalt) != ""
+}
+
+// readSecret returns the passphrase or key file contents from the
+// environment or, failing that, prompts for a passphrase.
+func readSecret(prompt string) ([]byte, error) {
+	if path := os.Getenv(envKeyFile); path != "" {
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading key file: %v", err)
+		}
+		return secret, nil
+	}
+	if passphrase := os.Getenv(envPassphrase); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("no key available, set %v, %v or %v", envKey, envKeyFile, envPassphrase)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("passphrase must not be empty")
+	}
+	return secret, nil
+}
+
+// loadCipher builds the cipher for an encrypted database and checks the key
+// against the value stored in the config.
+func loadCipher(config *Config) (*todoCipher, error) {
+	salt, err := base64.StdEncoding.DecodeString(config.GetValue(configSalt))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v in config: %v", configSalt, err)
+	}
+
+	var key []byte
+	if cached := os.Getenv(envKey); cached != "" {
+		if key, err = base64.StdEncoding.DecodeString(cached); err != nil {
+			return nil, fmt.Errorf("invalid %v: %v", envKey, err)
+		}
+	} else {
+		secret, err := readSecret("Passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if key, err = deriveKey(secret, salt); err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := newTodoCipher(key)
+	if err != nil {
+		return nil, errWrongKey
+	}
+	check, err := c.decrypt("check", config.GetValue(configCheck))
+	if err != nil || check != checkValue {
+		return nil, errWrongKey
+	}
+	return c, nil
+}
+
+// unlock sets up decryption for commands that read or write todos
+func unlock(d *DbTable, config *Config) {
+	if !encryptionEnabled(config) {
+		return
+	}
+	c, err := loadCipher(config)
+	if err != nil {
+		fmt.Println("Error unlocking database: ", err)
+		os.Exit(1)
+	}
+	d.cipher = c
+}
+
+// unlockCmd prints the derived key so that it can be cached in the
+// environment with eval "$(todo unlock)" instead of prompting every time.
+func unlockCmd(f *flag.FlagSet, config *Config) {
+	f.Parse(os.Args[2:])
+	if !encryptionEnabled(config) {
+		fmt.Println("Database is not encrypted")
+		os.Exit(1)
+	}
+	c, err := loadCipher(config)
+	if err != nil {
+		fmt.Println("Error unlocking database: ", err)
+		os.Exit(1)
+	}
+	fmt.Printf("export %v=%v\n", envKey, base64.StdEncoding.EncodeToString(c.key))
+}
+
+func encryptCmd(d *DbTable, f *flag.FlagSet, config *Config) {
+	f.Parse(os.Args[2:])
+	if encryptionEnabled(config) {
+		fmt.Println("Database is already encrypted")
+		os.Exit(1)
+	}
+
+	secret, err := readSecret("New passphrase: ")
+	if err != nil {
+		fmt.Println("Error reading passphrase: ", err)
+		os.Exit(1)
+	}
+	if os.Getenv(envKeyFile) == "" && os.Getenv(envPassphrase) == "" {
+		confirm, err := readSecret("Confirm passphrase: ")
+		if err != nil {
+			fmt.Println("Error reading passphrase: ", err)
+			os.Exit(1)
+		}
+		if string(confirm) != string(secret) {
+			fmt.Println("Passphrases do not match")
+			os.Exit(1)
+		}
+	}
+
+	salt := make([]byte, 16)
+	if _, err = rand.Read(salt); err != nil {
+		panic(err)
+	}
+	key, err := deriveKey(secret, salt)
+	if err != nil {
+		panic(err)
+	}
+	c, err := newTodoCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	check, err := c.encrypt("check", checkValue)
+	if err != nil {
+		panic(err)
+	}
+
+	// Save the key parameters first. If the conversion is interrupted the
+	// rows are left as plain text, which decrypt passes through unchanged
+	config.GetConfig()[configSalt] = base64.StdEncoding.EncodeToString(salt)
+	config.GetConfig()[configCheck] = check
+	if err = config.WriteConfig(); err != nil {
+		fmt.Println("Error writing config: ", err)
+		os.Exit(1)
+	}
+
+	count, err := d.convertTodos(c.encryptTodo)
+	if err != nil {
+		config.GetConfig()[configSalt] = ""
+		config.GetConfig()[configCheck] = ""
+		config.WriteConfig()
+		fmt.Println("Error encrypting todos: ", err)
+		os.Exit(1)
+	}
+	if err = d.scrub(); err != nil {
+		fmt.Println("Error removing plain text left in the database file, run todo decrypt then todo encrypt again: ", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Encrypted %d todos\n", count)
+}
+
+func decryptCmd(d *DbTable, f *flag.FlagSet, config *Config) {
+	f.Parse(os.Args[2:])
+	if !encryptionEnabled(config) {
+		fmt.Println("Database is not encrypted")
+		os.Exit(1)
+	}
+	c, err := loadCipher(config)
+	if err != nil {
+		fmt.Println("Error unlocking database: ", err)
+		os.Exit(1)
+	}
+
+	count, err := d.convertTodos(c.decryptTodo)
+	if err != nil {
+		fmt.Println("Error decrypting todos: ", err)
+		os.Exit(1)
+	}
+	config.GetConfig()[configSalt] = ""
+	config.GetConfig()[configCheck] = ""
+	if err = config.WriteConfig(); err != nil {
+		fmt.Println("Error writing config: ", err)
+		os.Exit(1)
+	}
+	if err = d.scrub(); err != nil {
+		fmt.Println("Error removing encrypted values left in the database file: ", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Decrypted %d todos\n", count)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func testCipher(t *testing.T, secret string) *todoCipher {
+	t.Helper()
+	key, err := deriveKey([]byte(secret), []byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newTodoCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	c := testCipher(t, "correct horse")
+	plain := todo{id: 1, name: "Call Globex", content: "about the ünïcode\ninvoice", priority: 2, tags: []string{"work"}}
+	sealed, err := c.encryptTodo(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{sealed.name, sealed.content} {
+		if !strings.HasPrefix(value, encPrefix) || strings.Contains(value, "Globex") || strings.Contains(value, "invoice") {
+			t.Errorf("encrypted value %q", value)
+		}
+	}
+	if sealed.priority != 2 || joinTags(sealed.tags) != "work" {
+		t.Errorf("encryptTodo changed more than the name and content: %+v", sealed)
+	}
+	opened, err := c.decryptTodo(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.name != plain.name || opened.content != plain.content {
+		t.Errorf("decryptTodo = %q %q, want %q %q", opened.name, opened.content, plain.name, plain.content)
+	}
+
+	// A fresh nonce each time
+	again, _ := c.encrypt("name", plain.name)
+	if again == sealed.name {
+		t.Error("encrypting the same value twice gave the same result")
+	}
+}
+
+func TestCipherWrongKey(t *testing.T) {
+	sealed, err := testCipher(t, "correct horse").encrypt("name", "Globex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = testCipher(t, "battery staple").decrypt("name", sealed); err != errWrongKey {
+		t.Errorf("decrypt with the wrong key error = %v, want %v", err, errWrongKey)
+	}
+}
+
+func TestCipherColumnBound(t *testing.T) {
+	c := testCipher(t, "correct horse")
+	sealed, _ := c.encrypt("name", "Globex")
+	if _, err := c.decrypt("content", sealed); err != errWrongKey {
+		t.Errorf("decrypt in another column error = %v, want %v", err, errWrongKey)
+	}
+}
+
+func TestCipherPlainAndCorrupt(t *testing.T) {
+	c := testCipher(t, "correct horse")
+	if value, err := c.decrypt("name", "left as plain text"); err != nil || value != "left as plain text" {
+		t.Errorf("decrypt of plain text = %q, %v", value, err)
+	}
+	if _, err := c.decrypt("name", encPrefix+"not base64!"); err == nil {
+		t.Error("decrypt of invalid base64 succeeded")
+	}
+	if _, err := c.decrypt("name", encPrefix+"AAAA"); err == nil {
+		t.Error("decrypt of a value shorter than the nonce succeeded")
+	}
+}
+
+// Encrypting must not leave the plain text anywhere in the database files,
+// including old pages and the WAL
+func TestEncryptLeavesNoPlainText(t *testing.T) {
+	d := testDb(t)
+	var todos []todo
+	for i := 0; i < 200; i++ {
+		todos = append(todos, todo{name: "Globex renewal", content: "Initech contract notes", priority: 1})
+	}
+	if _, err := d.insertTodos(todos); err != nil {
+		t.Fatal(err)
+	}
+
+	c := testCipher(t, "correct horse")
+	if _, err := d.convertTodos(c.encryptTodo); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.scrub(); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{d.dbName, d.dbName + "-wal"} {
+		raw, err := os.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		for _, word := range []string{"Globex", "Initech"} {
+			if n := bytes.Count(raw, []byte(word)); n > 0 {
+				t.Errorf("%v holds %q %d times after encrypting", file, word, n)
+			}
+		}
+	}
+
+	d.cipher = c
+	if got := d.getTodoById(1); got.name != "Globex renewal" || got.content != "Initech contract notes" {
+		t.Errorf("todo after encrypting = %q %q", got.name, got.content)
+	}
+	if _, err := d.convertTodos(c.decryptTodo); err != nil {
+		t.Fatal(err)
+	}
+	d.cipher = nil
+	if got := d.getTodoById(200); got.name != "Globex renewal" {
+		t.Errorf("todo after decrypting = %q", got.name)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date expressions accepted wherever the command line takes a date, resolved
+// in the local time zone:
+//
+//	2026-11-01, 2026-11-01 14:00     absolute
+//	now, today, tomorrow, yesterday
+//	fri, friday                      the coming friday, today if it is friday
+//	next fri                         the first friday after today
+//	in 3 days, +2w, -1d, +4h         relative to now, see durationUnits
+//	eod, eow, eom, eoy               last day of this day, week, month, year
+//
+// Any of the day expressions can be followed by a time, e.g. tomorrow 14:00
+// or fri 9am. A result without a time of day stands for the whole day.
+
+// dateLayouts are the absolute formats accepted
+var dateLayouts = []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"}
+
+// weekStart is the first day of the week used by eow and the calendar, set
+// with the weekstart config key
+var weekStart = time.Monday
+
+// Dates are in the local time zone unless the timezone config key names
+// another, e.g. Europe/London
+const (
+	configWeekStart = "weekstart"
+	configTimeZone  = "timezone"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+type durationUnit struct {
+	names []string
+	apply func(t time.Time, n int) time.Time
+	exact bool // keeps the time of day, day and longer units give a date
+}
+
+// durationUnits are the units of a relative date. m is a month,
+// use min for minutes.
+var durationUnits = []durationUnit{
+	{[]string{"min", "mins", "minute", "minutes"}, func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Minute) }, true},
+	{[]string{"h", "hr", "hrs", "hour", "hours"}, func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Hour) }, true},
+	{[]string{"d", "day", "days"}, func(t time.Time, n int) time.Time { return t.AddDate(0, 0, n) }, false},
+	{[]string{"w", "wk", "wks", "week", "weeks"}, func(t time.Time, n int) time.Time { return t.AddDate(0, 0, 7*n) }, false},
+	{[]string{"m", "mo", "month", "months"}, func(t time.Time, n int) time.Time { return t.AddDate(0, n, 0) }, false},
+	{[]string{"y", "yr", "year", "years"}, func(t time.Time, n int) time.Time { return t.AddDate(n, 0, 0) }, false},
+}
+
+var (
+	relativePattern = regexp.MustCompile(`^([+-])\s*(\d+)\s*([a-z]+)$`)
+	inPattern       = regexp.MustCompile(`^in\s+(\d+)\s*([a-z]+)$`)
+	agoPattern      = regexp.MustCompile(`^(\d+)\s*([a-z]+)\s+ago$`)
+	timePattern     = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+)
+
+// dateParser resolves date expressions relative to a fixed now so results
+// are reproducible
+type dateParser struct {
+	now       time.Time
+	weekStart time.Weekday
+}
+
+func newDateParser() dateParser {
+	return dateParser{now: time.Now(), weekStart: weekStart}
+}
+
+// setupDates applies the weekstart and timezone config keys
+func setupDates(config *Config) {
+	if value := config.GetValue(configWeekStart); value != "" {
+		day, err := parseWeekday(value)
+		if err != nil {
+			fmt.Println("Error reading weekstart in config: ", err)
+			os.Exit(1)
+		}
+		weekStart = day
+	}
+	if value := config.GetValue(configTimeZone); value != "" {
+		location, err := time.LoadLocation(value)
+		if err != nil {
+			fmt.Println("Error reading timezone in config: ", err)
+			os.Exit(1)
+		}
+		time.Local = location
+	}
+}
+
+func parseWeekday(value string) (time.Weekday, error) {
+	day, found := weekdays[strings.ToLower(value)]
+	if !found {
+		return 0, fmt.Errorf("unknown day %q, expected e.g. mon or sunday", value)
+	}
+	return day, nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	return newDateParser().parse(value)
+}
+
+func findUnit(name string) (durationUnit, bool) {
+	for _, u := range durationUnits {
+		for _, n := range u.names {
+			if n == name {
+				return u, true
+			}
+		}
+	}
+	return durationUnit{}, false
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func (p dateParser) parse(value string) (time.Time, error) {
+	expr := strings.Join(strings.Fields(value), " ")
+	if expr == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+	// Before lower casing, the T in 2026-11-01T14:00 must be upper case
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, expr, p.now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	expr = strings.ToLower(expr)
+
+	if t, ok, err := p.parseRelative(expr); ok || err != nil {
+		return t, err
+	}
+
+	// A day optionally followed by a time of day
+	day, clock := expr, ""
+	if i := strings.LastIndex(expr, " "); i >= 0 && timePattern.MatchString(expr[i+1:]) {
+		day, clock = expr[:i], expr[i+1:]
+	}
+	t, err := p.parseDay(day)
+	if err != nil {
+		// A time on its own is today at that time
+		if clock == "" && timePattern.MatchString(expr) && strings.ContainsAny(expr, ":apm") {
+			t, clock, err = startOfDay(p.now), expr, nil
+		} else {
+			return time.Time{}, fmt.Errorf("unrecognised date %q, try e.g. 2026-11-01, tomorrow, next fri, in 3 days or +2w", value)
+		}
+	}
+	if clock == "" {
+		return t, nil
+	}
+	return withClock(t, clock)
+}
+
+// parseRelative handles +2w, -1d, in 3 days and 2 days ago
+func (p dateParser) parseRelative(expr string) (time.Time, bool, error) {
+	var sign, count, unitName string
+	if m := relativePattern.FindStringSubmatch(expr); m != nil {
+		sign, count, unitName = m[1], m[2], m[3]
+	} else if m := inPattern.FindStringSubmatch(expr); m != nil {
+		sign, count, unitName = "+", m[1], m[2]
+	} else if m := agoPattern.FindStringSubmatch(expr); m != nil {
+		sign, count, unitName = "-", m[1], m[2]
+	} else {
+		return time.Time{}, false, nil
+	}
+	unit, found := findUnit(unitName)
+	if !found {
+		return time.Time{}, true, fmt.Errorf("unknown unit %q in %q, expected min, h, d, w, m or y", unitName, expr)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return time.Time{}, true, err
+	}
+	if sign == "-" {
+		n = -n
+	}
+	t := unit.apply(p.now, n)
+	if !unit.exact {
+		t = startOfDay(t)
+	}
+	return t, true, nil
+}
+
+func (p dateParser) parseDay(day string) (time.Time, error) {
+	today := startOfDay(p.now)
+	switch day {
+	case "now":
+		return p.now, nil
+	case "today", "eod":
+		return today, nil
+	case "tomorrow", "tmr", "tom":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "eow":
+		end := (p.weekStart + 6) % 7
+		return today.AddDate(0, 0, (int(end)-int(today.Weekday())+7)%7), nil
+	case "eom":
+		return time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, today.Location()), nil
+	case "eoy":
+		return time.Date(today.Year(), time.December, 31, 0, 0, 0, 0, today.Location()), nil
+	}
+
+	next := false
+	if strings.HasPrefix(day, "next ") {
+		next, day = true, strings.TrimPrefix(day, "next ")
+	}
+	weekday, found := weekdays[day]
+	if !found {
+		return time.Time{}, fmt.Errorf("unrecognised day %q", day)
+	}
+	days := (int(weekday) - int(today.Weekday()) + 7) % 7
+	if days == 0 && next {
+		days = 7
+	}
+	return today.AddDate(0, 0, days), nil
+}
+
+// withClock sets the time of day on t from 14:00, 9am or 9:30pm
+func withClock(t time.Time, clock string) (time.Time, error) {
+	m := timePattern.FindStringSubmatch(clock)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", clock)
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	if m[3] != "" {
+		if hour < 1 || hour > 12 {
+			return time.Time{}, fmt.Errorf("invalid time %q", clock)
+		}
+		hour %= 12
+		if m[3] == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return time.Time{}, fmt.Errorf("invalid time %q", clock)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location()), nil
+}
+
+// parseDue reads a -due flag value as unix seconds. "none" clears the due date.
+func parseDue(value string) (int64, error) {
+	if value == "none" {
+		return 0, nil
+	}
+	t, err := parseDate(value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+// formatDate shows a date, leaving out the time of day when it is midnight
+func formatDate(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// relativeDate describes how far t is from now, e.g. "in 3 days"
+func relativeDate(t time.Time, now time.Time) string {
+	t = t.In(now.Location())
+	days := daysBetween(now, t)
+	if t.Hour() != 0 || t.Minute() != 0 {
+		d := t.Sub(now).Round(time.Minute)
+		if d > -24*time.Hour && d < 24*time.Hour {
+			if d < 0 {
+				return formatHours(-d) + " ago"
+			}
+			return "in " + formatHours(d)
+		}
+	}
+	switch {
+	case days == 0:
+		return "today"
+	case days == 1:
+		return "tomorrow"
+	case days == -1:
+		return "yesterday"
+	case days < 0:
+		return fmt.Sprintf("%d days ago", -days)
+	default:
+		return fmt.Sprintf("in %d days", days)
+	}
+}
+
+// daysBetween counts the calendar days from from to to. The dates are moved
+// to UTC, where every day is 24 hours long, so that a daylight saving change
+// in between does not make a day short.
+func daysBetween(from time.Time, to time.Time) int {
+	utc := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return int(utc(to).Sub(utc(from)).Hours() / 24)
+}
+
+// formatHours shows a duration under a day as e.g. 2h30m
+func formatHours(d time.Duration) string {
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	default:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+}
+
+// dateCmd previews how a date expression resolves
+func dateCmd(f *flag.FlagSet) {
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: todo date <expression>")
+		fmt.Fprintln(f.Output(), "  e.g. today, tomorrow 14:00, next fri, in 3 days, eow, 2026-11-01 14:00, +2w")
+	}
+	// Not parsed as flags so that expressions such as -1d are accepted, only
+	// a --color in front is taken out
+	args, mode, err := extractColorFlag(os.Args[1:])
+	if err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(1)
+	}
+	if mode != "" {
+		f.Set("color", mode)
+	}
+	args = args[1:]
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		f.Usage()
+		os.Exit(1)
+	}
+	p := newDateParser()
+	t, err := p.parse(strings.Join(args, " "))
+	if err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%v (%v, %v)\n", formatDate(t), t.Format("Monday"), relativeDate(t, p.now))
+}
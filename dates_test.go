@@ -0,0 +1,223 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// wednesday is the fixed now of most of the date tests
+var wednesday = time.Date(2026, time.October, 14, 10, 30, 0, 0, time.UTC)
+
+func day(month time.Month, d int) time.Time {
+	return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func at(month time.Month, d int, hour int, minute int) time.Time {
+	return time.Date(2026, month, d, hour, minute, 0, 0, time.UTC)
+}
+
+func TestDateParser(t *testing.T) {
+	friday := at(time.October, 16, 9, 0)
+	sunday := at(time.October, 18, 20, 0)
+	tests := []struct {
+		now       time.Time
+		weekStart time.Weekday
+		expr      string
+		want      time.Time
+	}{
+		{wednesday, time.Monday, "now", wednesday},
+		{wednesday, time.Monday, "today", day(time.October, 14)},
+		{wednesday, time.Monday, "eod", day(time.October, 14)},
+		{wednesday, time.Monday, "tomorrow", day(time.October, 15)},
+		{wednesday, time.Monday, "Tomorrow", day(time.October, 15)},
+		{wednesday, time.Monday, "tmr", day(time.October, 15)},
+		{wednesday, time.Monday, "yesterday", day(time.October, 13)},
+
+		// A weekday is the coming one, today if it is that day, next is
+		// the first one after today
+		{wednesday, time.Monday, "fri", day(time.October, 16)},
+		{wednesday, time.Monday, "friday", day(time.October, 16)},
+		{wednesday, time.Monday, "next fri", day(time.October, 16)},
+		{wednesday, time.Monday, "wed", day(time.October, 14)},
+		{wednesday, time.Monday, "next wed", day(time.October, 21)},
+		{wednesday, time.Monday, "mon", day(time.October, 19)},
+		{friday, time.Monday, "fri", day(time.October, 16)},
+		{friday, time.Monday, "next fri", day(time.October, 23)},
+		{friday, time.Monday, "  Next   FRI ", day(time.October, 23)},
+
+		{wednesday, time.Monday, "in 3 days", day(time.October, 17)},
+		{wednesday, time.Monday, "in 1 week", day(time.October, 21)},
+		{wednesday, time.Monday, "3 days ago", day(time.October, 11)},
+		{wednesday, time.Monday, "in 2 hours", at(time.October, 14, 12, 30)},
+
+		// The end of the week depends on when it starts
+		{wednesday, time.Monday, "eow", day(time.October, 18)},
+		{wednesday, time.Sunday, "eow", day(time.October, 17)},
+		{wednesday, time.Saturday, "eow", day(time.October, 16)},
+		{sunday, time.Monday, "eow", day(time.October, 18)},
+		{sunday, time.Sunday, "eow", day(time.October, 24)},
+		{wednesday, time.Monday, "eom", day(time.October, 31)},
+		{wednesday, time.Monday, "eoy", day(time.December, 31)},
+
+		{wednesday, time.Monday, "+2w", day(time.October, 28)},
+		{wednesday, time.Monday, "-1d", day(time.October, 13)},
+		{wednesday, time.Monday, "+ 1 d", day(time.October, 15)},
+		{wednesday, time.Monday, "+1m", day(time.November, 14)},
+		{wednesday, time.Monday, "+1y", time.Date(2027, time.October, 14, 0, 0, 0, 0, time.UTC)},
+		{wednesday, time.Monday, "+4h", at(time.October, 14, 14, 30)},
+		{wednesday, time.Monday, "-30min", at(time.October, 14, 10, 0)},
+
+		{wednesday, time.Monday, "2026-11-01", day(time.November, 1)},
+		{wednesday, time.Monday, "2026-11-01 14:00", at(time.November, 1, 14, 0)},
+		{wednesday, time.Monday, "2026-11-01T14:00", at(time.November, 1, 14, 0)},
+
+		{wednesday, time.Monday, "tomorrow 14:00", at(time.October, 15, 14, 0)},
+		{wednesday, time.Monday, "fri 9am", at(time.October, 16, 9, 0)},
+		{wednesday, time.Monday, "next fri 9:30pm", at(time.October, 16, 21, 30)},
+		{wednesday, time.Monday, "today 12am", at(time.October, 14, 0, 0)},
+		{wednesday, time.Monday, "today 12pm", at(time.October, 14, 12, 0)},
+		{wednesday, time.Monday, "14:00", at(time.October, 14, 14, 0)},
+		{wednesday, time.Monday, "5pm", at(time.October, 14, 17, 0)},
+	}
+	for _, test := range tests {
+		p := dateParser{now: test.now, weekStart: test.weekStart}
+		got, err := p.parse(test.expr)
+		if err != nil {
+			t.Errorf("parse(%q) at %v error: %v", test.expr, test.now, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("parse(%q) at %v = %v, want %v", test.expr, test.now, got, test.want)
+		}
+	}
+}
+
+// Days are counted on the calendar and hours on the clock, so that across a
+// daylight saving change a day is not always 24 hours
+func TestDateParserDaylightSaving(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Clocks go forward at 2am on 8 March 2026 and back at 2am on 1 November
+	spring := time.Date(2026, time.March, 7, 12, 0, 0, 0, newYork)
+	autumn := time.Date(2026, time.October, 31, 12, 0, 0, 0, newYork)
+	tests := []struct {
+		now  time.Time
+		expr string
+		want time.Time
+	}{
+		{spring, "tomorrow", time.Date(2026, time.March, 8, 0, 0, 0, 0, newYork)},
+		{spring, "tomorrow 14:00", time.Date(2026, time.March, 8, 14, 0, 0, 0, newYork)},
+		{spring, "+1d", time.Date(2026, time.March, 8, 0, 0, 0, 0, newYork)},
+		{spring, "in 2 days", time.Date(2026, time.March, 9, 0, 0, 0, 0, newYork)},
+		{spring, "+24h", time.Date(2026, time.March, 8, 13, 0, 0, 0, newYork)},
+		{spring, "sun 9am", time.Date(2026, time.March, 8, 9, 0, 0, 0, newYork)},
+		{autumn, "tomorrow 14:00", time.Date(2026, time.November, 1, 14, 0, 0, 0, newYork)},
+		{autumn, "+24h", time.Date(2026, time.November, 1, 11, 0, 0, 0, newYork)},
+		{autumn, "+1w", time.Date(2026, time.November, 7, 0, 0, 0, 0, newYork)},
+		{autumn, "2026-11-02 09:00", time.Date(2026, time.November, 2, 9, 0, 0, 0, newYork)},
+	}
+	for _, test := range tests {
+		p := dateParser{now: test.now, weekStart: time.Monday}
+		got, err := p.parse(test.expr)
+		if err != nil {
+			t.Errorf("parse(%q) at %v error: %v", test.expr, test.now, err)
+			continue
+		}
+		if !got.Equal(test.want) || got.Location() != newYork {
+			t.Errorf("parse(%q) at %v = %v, want %v", test.expr, test.now, got, test.want)
+		}
+	}
+}
+
+func TestDateParserErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		msg  string
+	}{
+		{"", "empty date"},
+		{"   ", "empty date"},
+		{"someday", `unrecognised date "someday", try e.g. 2026-11-01, tomorrow, next fri, in 3 days or +2w`},
+		{"next someday", `unrecognised date "next someday"`},
+		{"2026-13-01", `unrecognised date "2026-13-01"`},
+		{"in 3 dayz", `unknown unit "dayz" in "in 3 dayz", expected min, h, d, w, m or y`},
+		{"+2q", `unknown unit "q" in "+2q"`},
+		{"tomorrow 25:00", `invalid time "25:00"`},
+		{"tomorrow 9:75", `invalid time "9:75"`},
+		{"fri 13pm", `invalid time "13pm"`},
+		{"0am", `invalid time "0am"`},
+	}
+	for _, test := range tests {
+		p := dateParser{now: wednesday, weekStart: time.Monday}
+		_, err := p.parse(test.expr)
+		if err == nil || !strings.HasPrefix(err.Error(), test.msg) {
+			t.Errorf("parse(%q) error = %v, want %q", test.expr, err, test.msg)
+		}
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	if day, err := parseWeekday("Sunday"); err != nil || day != time.Sunday {
+		t.Errorf("parseWeekday(Sunday) = %v, %v", day, err)
+	}
+	if _, err := parseWeekday("funday"); err == nil || err.Error() != `unknown day "funday", expected e.g. mon or sunday` {
+		t.Errorf("parseWeekday(funday) error = %v", err)
+	}
+}
+
+func TestRelativeDate(t *testing.T) {
+	tests := []struct {
+		date time.Time
+		want string
+	}{
+		{day(time.October, 14), "today"},
+		{day(time.October, 15), "tomorrow"},
+		{day(time.October, 13), "yesterday"},
+		{day(time.October, 20), "in 6 days"},
+		{day(time.October, 4), "10 days ago"},
+		{at(time.October, 14, 13, 0), "in 2h30m"},
+		{at(time.October, 14, 10, 15), "15m ago"},
+		{at(time.October, 15, 9, 0), "in 22h30m"},
+		{at(time.October, 16, 9, 0), "in 2 days"},
+	}
+	for _, test := range tests {
+		if got := relativeDate(test.date, wednesday); got != test.want {
+			t.Errorf("relativeDate(%v) = %q, want %q", test.date, got, test.want)
+		}
+	}
+}
+
+// A day across a daylight saving change is 23 or 25 hours, it still counts
+// as one
+func TestRelativeDateDaylightSaving(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := func(month time.Month, d int, hour int) time.Time {
+		return time.Date(2026, month, d, hour, 0, 0, 0, newYork)
+	}
+	tests := []struct {
+		date time.Time
+		now  time.Time
+		want string
+	}{
+		// Clocks go forward at 2am on 8 March 2026 and back at 2am on 1 November
+		{local(time.March, 9, 0), local(time.March, 8, 0), "tomorrow"},
+		{local(time.March, 10, 0), local(time.March, 7, 23), "in 3 days"},
+		{local(time.March, 7, 0), local(time.March, 9, 12), "2 days ago"},
+		{local(time.November, 1, 0), local(time.October, 31, 12), "tomorrow"},
+		{local(time.November, 2, 0), local(time.October, 31, 12), "in 2 days"},
+		{local(time.October, 31, 0), local(time.November, 1, 23), "yesterday"},
+		// The date is read in the zone of now
+		{time.Date(2026, time.March, 9, 3, 0, 0, 0, time.UTC), local(time.March, 7, 12), "tomorrow"},
+	}
+	for _, test := range tests {
+		if got := relativeDate(test.date, test.now); got != test.want {
+			t.Errorf("relativeDate(%v) at %v = %q, want %q", test.date, test.now, got, test.want)
+		}
+	}
+}
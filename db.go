@@ -2,16 +2,21 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"math/rand"
 	"os"
-
-	_ "github.com/mattn/go-sqlite3"
+	"strings"
+	"time"
 )
 
 type DbTable struct {
 	dbName    string
 	tableName string
 	tableType todo
+	cipher    *todoCipher  // nil unless the database is encrypted
+	context   *todoContext // nil unless a context is active
+	migrated  bool
 	// tableSchema map[string]string
 }
 
@@ -30,22 +35,136 @@ func dbType(goType string) string {
 	}
 }
 
+// column describes a single column of the todo table
+type column struct {
+	name    string
+	sqlType string
+	extra   string
+	fill    string // run after the column is added to an existing table
+}
+
+// todoSchema is the shape of the table created by createDB. Anything that
+// needs to reason about the table layout, such as doctor, should use it
+// rather than repeating the column list.
+var todoSchema = []column{
+	{"id", "INTEGER", "PRIMARY KEY AUTOINCREMENT", ""},
+	{"name", "TEXT", "", ""},
+	{"content", "TEXT", "", ""},
+	{"priority", "INTEGER", "", ""},
+	{"completed", "INTEGER", "", ""},
+	{"version", "INTEGER", "NOT NULL DEFAULT 0", ""},
+	// Manual order within a priority. New todos go to the bottom and a move
+	// takes the midpoint of its new neighbours so other rows are untouched
+	{"position", "REAL", "NOT NULL DEFAULT 0", "UPDATE %v SET position = id;"},
+	// Unix seconds, 0 when unknown or unset
+	{"created", "INTEGER", "NOT NULL DEFAULT 0", ""},
+	{"due", "INTEGER", "NOT NULL DEFAULT 0", ""},
+	// Space separated, see joinTags
+	{"tags", "TEXT", "NOT NULL DEFAULT ''", ""},
+	{"context", "TEXT", "NOT NULL DEFAULT ''", ""},
+	// Unix seconds, set by updateTodoById when a todo is completed and
+	// cleared when it is reopened
+	{"completed_at", "INTEGER", "NOT NULL DEFAULT 0", ""},
+}
+
+// todoFields is the column list read into a todo by scanTodo
+const todoFields = "id, name, content, priority, completed, version, position, created, due, tags, context, completed_at"
+
+// errConflict is returned when a todo was changed by another process between
+// being read and written back
+var errConflict = errors.New("todo was changed by another process, view it again and retry")
+
+// queryExecer is satisfied by both *sql.DB and *sql.Tx
+type queryExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTodo(row rowScanner) (todo, error) {
+	var t todo
+	var tags string
+	err := row.Scan(&t.id, &t.name, &t.content, &t.priority, &t.completed, &t.version, &t.position, &t.created, &t.due, &tags, &t.context, &t.completedAt)
+	t.tags = splitTags(tags)
+	return t, err
+}
+
+// open connects to the database set up for use by several processes at
+// once. WAL journaling lets readers carry on while another process writes,
+// the busy timeout waits for a lock rather than failing straight away and
+// immediate transactions take the write lock up front so two writers cannot
+// deadlock upgrading from a read lock. Columns added to todoSchema since the
+// database was created are added on first use.
+func (d *DbTable) open() (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", d.dbName+"?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	if !d.migrated {
+		// Checked outside a transaction first, as with _txlock=immediate
+		// every transaction takes the write lock
+		var existing map[string]string
+		err = retry(func() error {
+			existing, err = d.tableColumns(db)
+			return err
+		})
+		if err == nil && hasMissingColumns(existing) {
+			// In a transaction so that two processes cannot both add a column
+			err = retry(func() error {
+				tx, err := db.Begin()
+				if err != nil {
+					return err
+				}
+				defer tx.Rollback()
+				if err = d.addMissingColumns(tx); err != nil {
+					return err
+				}
+				return tx.Commit()
+			})
+		}
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		d.migrated = true
+	}
+	return db, nil
+}
+
+// retry runs f again with exponential backoff and jitter for as long as it
+// fails because the database is locked, up to a limit.
+func retry(f func() error) error {
+	delay := 50 * time.Millisecond
+	var err error
+	for attempt := 0; attempt < 6; attempt++ {
+		if err = f(); !isLocked(err) {
+			return err
+		}
+		time.Sleep(delay + time.Duration(rand.Int63n(int64(delay))))
+		delay *= 2
+	}
+	return err
+}
+
 func (d *DbTable) createDB() (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", d.dbName)
+	db, err := d.open()
 	if err != nil {
 		panic(err)
 	}
-    
+
 	// Create table
+	var columns []string
+	for _, c := range todoSchema {
+		columns = append(columns, strings.TrimSpace(c.name+" "+c.sqlType+" "+c.extra))
+	}
 	sqlStmt := fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %v (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT,
-			content TEXT,
-			priority INTEGER,
-			completed INTEGER
+			%v
 		);
-	`, d.tableName)
+	`, d.tableName, strings.Join(columns, ",\n\t\t\t"))
 
 	_, err = db.Exec(sqlStmt)
 	if err != nil {
@@ -57,98 +176,215 @@ func (d *DbTable) createDB() (*sql.DB, error) {
 	return db, err
 }
 
+// tableColumns returns the columns of the todo table mapped to their
+// declared types. An empty map means the table does not exist.
+func (d *DbTable) tableColumns(db queryExecer) (map[string]string, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%v);", d.tableName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := map[string]string{}
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err = rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = strings.ToUpper(colType)
+	}
+	return columns, rows.Err()
+}
+
+// hasMissingColumns reports whether the existing table lacks a column in
+// todoSchema. A table that does not exist yet is created by 'todo init'.
+func hasMissingColumns(existing map[string]string) bool {
+	if len(existing) == 0 {
+		return false
+	}
+	for _, c := range todoSchema {
+		if _, found := existing[c.name]; !found {
+			return true
+		}
+	}
+	return false
+}
+
+// addMissingColumns adds any column in todoSchema that the existing table
+// lacks. Primary key columns cannot be added to an existing table.
+func (d *DbTable) addMissingColumns(db queryExecer) error {
+	existing, err := d.tableColumns(db)
+	if err != nil {
+		return err
+	}
+	for _, c := range todoSchema {
+		if _, found := existing[c.name]; found {
+			continue
+		}
+		if strings.Contains(c.extra, "PRIMARY KEY") {
+			return fmt.Errorf("cannot add primary key column %v to existing table %v", c.name, d.tableName)
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %v ADD COLUMN %v;", d.tableName, strings.TrimSpace(c.name+" "+c.sqlType+" "+c.extra))
+		if _, err = db.Exec(stmt); err != nil {
+			return err
+		}
+		if c.fill != "" {
+			if _, err = db.Exec(fmt.Sprintf(c.fill, d.tableName)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (d *DbTable) deleteDb() error {
 	return os.Remove(d.dbName)
 }
 
 func (d *DbTable) deleteAll() error {
-	db, err := sql.Open("sqlite3", d.dbName)
+	db, err := d.open()
 	if err != nil {
 		panic(err)
 	}
-	_, err = db.Exec("DELETE FROM todo;")
-	return err
+	defer db.Close()
+	return retry(func() error {
+		_, err := db.Exec("DELETE FROM todo;")
+		return err
+	})
 }
 
-func (d *DbTable) insertTodo(t todo) (int, error) {
-	db, err := sql.Open("sqlite3", d.dbName)
-	if err != nil {
-		panic(err)
+// insertInto adds t, which must already be encrypted, at the bottom of its
+// priority
+func insertInto(db queryExecer, t todo) (int, error) {
+	now := time.Now().Unix()
+	completedAt := int64(0)
+	if t.completed == 1 {
+		completedAt = now
 	}
-	res, err := db.Exec("INSERT INTO todo (name, content, priority, completed) VALUES (?, ?, ?, ?);", t.name, t.content, t.priority, t.completed)
+	res, err := db.Exec("INSERT INTO todo (name, content, priority, completed, position, created, due, tags, context, completed_at) VALUES (?, ?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM todo), ?, ?, ?, ?, ?);",
+		t.name, t.content, t.priority, t.completed, now, t.due, joinTags(t.tags), t.context, completedAt)
 	if err != nil {
-		if err.Error() == "no such table: todo" {
-			fmt.Println("Database not found. Run 'todo init' to create a new database.")
-			os.Exit(1)
-		}
-		panic(err)
-	}
-	db.Close()
-	var id int64
-	if id, err = res.LastInsertId(); err != nil {
 		return 0, err
 	}
+	id, err := res.LastInsertId()
 	return int(id), err
 }
 
-func (d *DbTable) getAllTodos(limit int) []todo {
-	db, err := sql.Open("sqlite3", d.dbName)
+func (d *DbTable) insertTodo(t todo) (int, error) {
+	ids, err := d.insertTodos([]todo{t})
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
-	rows, err := db.Query("SELECT * FROM todo ORDER BY completed, priority DESC LIMIT ?;", limit)
+	return ids[0], nil
+}
+
+// insertTodos adds all of todos or, if any fails, none of them
+func (d *DbTable) insertTodos(todos []todo) ([]int, error) {
+	db, err := d.open()
 	if err != nil {
 		panic(err)
 	}
-	defer rows.Close()
-	var todos []todo
-	for rows.Next() {
-		var t todo
-		err = rows.Scan(&t.id, &t.name, &t.content, &t.priority, &t.completed)
+	defer db.Close()
+	// Encrypted into a copy so the caller's todos are left as plain text
+	encrypted := make([]todo, len(todos))
+	for i, t := range todos {
+		if encrypted[i], err = d.encryptTodo(t); err != nil {
+			return nil, err
+		}
+	}
+	var ids []int
+	err = retry(func() error {
+		ids = nil
+		tx, err := db.Begin()
 		if err != nil {
-			panic(err)
+			return err
 		}
-		todos = append(todos, t)
+		defer tx.Rollback()
+		for _, t := range encrypted {
+			id, err := insertInto(tx, t)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		if err.Error() == "no such table: todo" {
+			fmt.Println("Database not found. Run 'todo init' to create a new database.")
+			os.Exit(1)
+		}
+		return nil, err
 	}
-	db.Close()
-	return todos
+	return ids, nil
 }
 
 func (d *DbTable) getTodoById(id int) todo {
-	db, err := sql.Open("sqlite3", d.dbName)
+	db, err := d.open()
 	if err != nil {
 		panic(err)
 	}
-	var t todo
-	err = db.QueryRow("SELECT * FROM todo WHERE id = ?;", id).Scan(&t.id, &t.name, &t.content, &t.priority, &t.completed)
+	t, err := scanTodo(db.QueryRow("SELECT "+todoFields+" FROM todo WHERE id = ?;", id))
 	if err != nil {
 		panic(err)
 	}
 	db.Close()
-	return t
+	return d.decryptTodo(t)
 }
 
+// updateTodoById writes t back only if the row still has the version t was
+// read at, so that an edit made by another process in the meantime is
+// reported as errConflict rather than silently overwritten. completed_at is
+// set when t is completed and cleared when it is reopened.
 func (d *DbTable) updateTodoById(id int, t todo) error {
-	db, err := sql.Open("sqlite3", d.dbName)
+	db, err := d.open()
 	if err != nil {
 		panic(err)
 	}
-	_, err = db.Exec("UPDATE todo SET name = ?, content = ?, priority = ?, completed = ? WHERE id = ?;", t.name, t.content, t.priority, t.completed, id)
+	defer db.Close()
+	if t, err = d.encryptTodo(t); err != nil {
+		return err
+	}
+	var res sql.Result
+	err = retry(func() error {
+		res, err = db.Exec("UPDATE todo SET name = ?, content = ?, priority = ?, completed = ?, due = ?, tags = ?, context = ?, "+
+			"completed_at = CASE WHEN completed = ? THEN completed_at WHEN ? = 1 THEN ? ELSE 0 END, version = version + 1 WHERE id = ? AND version = ?;",
+			t.name, t.content, t.priority, t.completed, t.due, joinTags(t.tags), t.context, t.completed, t.completed, time.Now().Unix(), id, t.version)
+		return err
+	})
 	if err != nil {
-		panic(err)
+		return err
 	}
-	db.Close()
-	return err
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		var exists int
+		if err = db.QueryRow("SELECT COUNT(*) FROM todo WHERE id = ?;", id).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return fmt.Errorf("todo %d does not exist", id)
+		}
+		return errConflict
+	}
+	return nil
 }
 
 func (d *DbTable) deleteTodoById(id int) (int, error) {
-	db, err := sql.Open("sqlite3", d.dbName)
+	db, err := d.open()
 	if err != nil {
 		panic(err)
 	}
-	res, err := db.Exec("DELETE FROM todo WHERE id = ?;", id)
+	var res sql.Result
+	err = retry(func() error {
+		res, err = db.Exec("DELETE FROM todo WHERE id = ?;", id)
+		return err
+	})
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
 	db.Close()
 	var newId int64
@@ -158,53 +394,462 @@ func (d *DbTable) deleteTodoById(id int) (int, error) {
 	return int(newId), err
 }
 
-func (d *DbTable) getTodosCountByStatus(status int) int {
-	db, err := sql.Open("sqlite3", d.dbName)
+// todoCount is the number of todos with a priority and status
+type todoCount struct {
+	priority  Priority
+	completed int
+	count     int
+}
+
+// getTodoCounts counts the todos matching f by priority and status
+func (d *DbTable) getTodoCounts(f *todoFilter) []todoCount {
+	f = d.scope(f)
+	where := ""
+	if f.where != "" {
+		where = " WHERE " + f.where
+	}
+	db, err := d.open()
 	if err != nil {
 		panic(err)
 	}
-	var count int
-	err = db.QueryRow("SELECT COUNT(*) FROM todo WHERE completed = ?;", status).Scan(&count)
+	defer db.Close()
+	rows, err := db.Query("SELECT priority, completed, COUNT(*) FROM todo"+where+" GROUP BY priority, completed ORDER BY priority, completed;", f.args...)
 	if err != nil {
 		panic(err)
 	}
-	db.Close()
+	defer rows.Close()
+	var counts []todoCount
+	for rows.Next() {
+		var c todoCount
+		if err = rows.Scan(&c.priority, &c.completed, &c.count); err != nil {
+			panic(err)
+		}
+		counts = append(counts, c)
+	}
+	return counts
+}
+
+// getAddedPerWeek counts the todos matching f that were added in each of the
+// weeks before now, most recent first. The filter's limit is ignored and the
+// todos themselves are never read.
+func (d *DbTable) getAddedPerWeek(f *todoFilter, now time.Time, weeks int) []int {
+	f = d.scope(f)
+	added := &todoFilter{where: f.where, args: f.args}
+	added.and("created >= ? AND created < ?", now.AddDate(0, 0, -7*weeks).Unix(), now.Unix())
+	db, err := d.open()
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	args := append([]interface{}{now.Unix()}, added.args...)
+	rows, err := db.Query("SELECT (? - created) / 604800 AS week, COUNT(*) FROM todo WHERE "+added.where+" GROUP BY week;", args...)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	counts := make([]int, weeks)
+	for rows.Next() {
+		var week, count int
+		if err = rows.Scan(&week, &count); err != nil {
+			panic(err)
+		}
+		if week >= 0 && week < weeks {
+			counts[week] += count
+		}
+	}
+	return counts
+}
+
+func (d *DbTable) getTodosCountByStatus(status int) int {
+	count := 0
+	for _, c := range d.getTodoCounts(&todoFilter{}) {
+		if c.completed == status {
+			count += c.count
+		}
+	}
 	return count
 }
 
 func (d *DbTable) getTodosCount() int {
-	db, err := sql.Open("sqlite3", d.dbName)
+	count := 0
+	for _, c := range d.getTodoCounts(&todoFilter{}) {
+		count += c.count
+	}
+	return count
+}
+
+// getCompletions returns the todos matching f that were completed from
+// since until until, in the order they were completed. Todos completed
+// before completed_at was recorded are left out.
+func (d *DbTable) getCompletions(f *todoFilter, since time.Time, until time.Time) []todo {
+	completed := *f
+	completed.and("completed = 1 AND completed_at != 0 AND completed_at >= ? AND completed_at < ?", since.Unix(), until.Unix())
+	completed.sort = []sortKey{{field: "completed_at"}}
+	completed.limit = 0
+	return d.queryTodos(&completed, -1)
+}
+
+// checkFilter reports a filter that cannot be run, in the active context
+func (d *DbTable) checkFilter(f *todoFilter) error {
+	if d.scope(f).usesText() && d.cipher != nil {
+		return errors.New("name and content cannot be filtered or sorted on an encrypted database")
+	}
+	return nil
+}
+
+// scope restricts a filter to the active context, if any
+func (d *DbTable) scope(f *todoFilter) *todoFilter {
+	if d.context == nil {
+		return f
+	}
+	scoped := *f
+	scoped.merge(d.context.compiled)
+	return &scoped
+}
+
+// inScope reports whether the todo with id exists and is in the active
+// context, if any
+func (d *DbTable) inScope(id int) (bool, error) {
+	f := &todoFilter{}
+	f.and("id = ?", id)
+	count, err := d.countTodos(f)
+	return count > 0, err
+}
+
+// queryTodos returns the todos matching a compiled filter expression,
+// limited to limit rows unless the filter has its own limit. A limit of -1
+// returns every match.
+func (d *DbTable) queryTodos(f *todoFilter, limit int) []todo {
+	f = d.scope(f)
+	if f.limit > 0 {
+		limit = f.limit
+	}
+	where := ""
+	if f.where != "" {
+		where = " WHERE " + f.where
+	}
+	db, err := d.open()
 	if err != nil {
 		panic(err)
 	}
-	var count int
-	err = db.QueryRow("SELECT COUNT(*) FROM todo;").Scan(&count)
+	defer db.Close()
+	args := append(append([]interface{}{}, f.args...), limit)
+	rows, err := db.Query("SELECT "+todoFields+" FROM todo"+where+" ORDER BY "+f.orderBy()+" LIMIT ?;", args...)
 	if err != nil {
 		panic(err)
 	}
+	defer rows.Close()
+	var todos []todo
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			panic(err)
+		}
+		todos = append(todos, d.decryptTodo(t))
+	}
+	return todos
+}
+
+// queryPage returns page, counted from 1, of the todos matching f with
+// pageSize todos to a page. The order is total so a row is never shown on
+// two pages, see orderKeys.
+func (d *DbTable) queryPage(f *todoFilter, pageSize int, page int) []todo {
+	return d.queryRange(d.scope(f), pageSize, (page-1)*pageSize)
+}
+
+// queryAfter returns up to pageSize todos matching f that sort after the todo
+// with id after, which need not match f itself. The rows are found by their
+// sort key rather than OFFSET so that nothing before them is read.
+func (d *DbTable) queryAfter(f *todoFilter, pageSize int, after int) ([]todo, error) {
+	scoped := *d.scope(f)
+	f = &scoped
+	keys := f.orderKeys()
+	columns := make([]string, len(keys))
+	for i, k := range keys {
+		columns[i] = k.column
+	}
+
+	db, err := d.open()
+	if err != nil {
+		panic(err)
+	}
+	cursor := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range cursor {
+		pointers[i] = &cursor[i]
+	}
+	err = db.QueryRow("SELECT "+strings.Join(columns, ", ")+" FROM todo WHERE id = ?;", after).Scan(pointers...)
 	db.Close()
-	return count
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("todo %d does not exist", after)
+	}
+	if err != nil {
+		panic(err)
+	}
+	f.after(cursor)
+	return d.queryRange(f, pageSize, 0), nil
 }
 
-func (d *DbTable) getTodosByStatus(status int, limit int) []todo {
-	db, err := sql.Open("sqlite3", d.dbName)
+// queryRange reads limit todos matching an already scoped filter, skipping
+// the first offset
+func (d *DbTable) queryRange(f *todoFilter, limit int, offset int) []todo {
+	db, err := d.open()
 	if err != nil {
 		panic(err)
 	}
-	rows, err := db.Query("SELECT * FROM todo WHERE completed = ? ORDER BY priority DESC LIMIT ?;", status, limit)
+	defer db.Close()
+	where := ""
+	if f.where != "" {
+		where = " WHERE " + f.where
+	}
+	args := append(append([]interface{}{}, f.args...), limit, offset)
+	rows, err := db.Query("SELECT "+todoFields+" FROM todo"+where+" ORDER BY "+f.orderBy()+" LIMIT ? OFFSET ?;", args...)
 	if err != nil {
 		panic(err)
 	}
 	defer rows.Close()
 	var todos []todo
 	for rows.Next() {
-		var t todo
-		err = rows.Scan(&t.id, &t.name, &t.content, &t.priority, &t.completed)
+		t, err := scanTodo(rows)
 		if err != nil {
 			panic(err)
 		}
-		todos = append(todos, t)
+		todos = append(todos, d.decryptTodo(t))
 	}
-	db.Close()
 	return todos
 }
+
+// countTodos returns the number of todos matching a filter, ignoring its
+// limit. A filter on name or content is an error on an encrypted database
+// rather than silently matching nothing.
+func (d *DbTable) countTodos(f *todoFilter) (int, error) {
+	if err := d.checkFilter(f); err != nil {
+		return 0, err
+	}
+	f = d.scope(f)
+	where := ""
+	if f.where != "" {
+		where = " WHERE " + f.where
+	}
+	db, err := d.open()
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM todo"+where+";", f.args...).Scan(&count)
+	if err != nil {
+		panic(err)
+	}
+	return count, nil
+}
+
+func (d *DbTable) encryptTodo(t todo) (todo, error) {
+	if d.cipher == nil {
+		return t, nil
+	}
+	return d.cipher.encryptTodo(t)
+}
+
+func (d *DbTable) decryptTodo(t todo) todo {
+	if d.cipher == nil {
+		return t
+	}
+	plain, err := d.cipher.decryptTodo(t)
+	if err != nil {
+		fmt.Printf("Error decrypting todo %d: %v\n", t.id, err)
+		os.Exit(1)
+	}
+	return plain
+}
+
+// convertTodos rewrites the name and content of every todo with convert in a
+// single transaction, used to encrypt or decrypt an existing database.
+func (d *DbTable) convertTodos(convert func(todo) (todo, error)) (int, error) {
+	db, err := d.open()
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	var tx *sql.Tx
+	err = retry(func() error {
+		tx, err = db.Begin()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id, name, content FROM todo;")
+	if err != nil {
+		return 0, err
+	}
+	var todos []todo
+	for rows.Next() {
+		var t todo
+		if err = rows.Scan(&t.id, &t.name, &t.content); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		todos = append(todos, t)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, t := range todos {
+		if t, err = convert(t); err != nil {
+			return 0, fmt.Errorf("todo %d: %v", t.id, err)
+		}
+		if _, err = tx.Exec("UPDATE todo SET name = ?, content = ? WHERE id = ?;", t.name, t.content, t.id); err != nil {
+			return 0, err
+		}
+	}
+	return len(todos), tx.Commit()
+}
+
+// scrub removes the old copies of rewritten rows from the database file. An
+// update leaves the previous values in the WAL and in freed pages, so after
+// encrypting they would still hold the plain text. The WAL is checkpointed
+// into the database, VACUUM rebuilds it from the live rows only and the
+// rebuilt pages are checkpointed again so the WAL is left empty.
+func (d *DbTable) scrub() error {
+	db, err := d.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for _, stmt := range []string{"PRAGMA wal_checkpoint(TRUNCATE);", "VACUUM;", "PRAGMA wal_checkpoint(TRUNCATE);"} {
+		if err = retry(func() error {
+			_, err := db.Exec(stmt)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type moveWhere int
+
+const (
+	moveBefore moveWhere = iota
+	moveAfter
+	moveTop
+	moveBottom
+)
+
+// moveTodo changes the manual position of todo id relative to the todo
+// anchor, or to the top or bottom of the list, where anchor is ignored.
+func (d *DbTable) moveTodo(id int, where moveWhere, anchor int) error {
+	db, err := d.open()
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	return retry(func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		position, err := newPosition(tx, id, where, anchor)
+		if errors.Is(err, errNoGap) {
+			// Only reached after many moves into the same gap
+			if err = renumberPositions(tx); err != nil {
+				return err
+			}
+			position, err = newPosition(tx, id, where, anchor)
+		}
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec("UPDATE todo SET position = ? WHERE id = ?;", position, id)
+		if err != nil {
+			return err
+		}
+		if updated, err := res.RowsAffected(); err != nil {
+			return err
+		} else if updated == 0 {
+			return fmt.Errorf("todo %d does not exist", id)
+		}
+		return tx.Commit()
+	})
+}
+
+var errNoGap = errors.New("no room between positions")
+
+// newPosition finds the position for id without changing any other row
+func newPosition(tx *sql.Tx, id int, where moveWhere, anchor int) (float64, error) {
+	var position sql.NullFloat64
+	switch where {
+	case moveTop:
+		err := tx.QueryRow("SELECT MIN(position) FROM todo WHERE id != ?;", id).Scan(&position)
+		return position.Float64 - 1, err
+	case moveBottom:
+		err := tx.QueryRow("SELECT MAX(position) FROM todo WHERE id != ?;", id).Scan(&position)
+		return position.Float64 + 1, err
+	}
+
+	var anchorPosition float64
+	err := tx.QueryRow("SELECT position FROM todo WHERE id = ?;", anchor).Scan(&anchorPosition)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("todo %d does not exist", anchor)
+	} else if err != nil {
+		return 0, err
+	}
+
+	var neighbour float64
+	if where == moveBefore {
+		err = tx.QueryRow("SELECT MAX(position) FROM todo WHERE position < ? AND id != ?;", anchorPosition, id).Scan(&position)
+		neighbour = anchorPosition - 1
+	} else {
+		err = tx.QueryRow("SELECT MIN(position) FROM todo WHERE position > ? AND id != ?;", anchorPosition, id).Scan(&position)
+		neighbour = anchorPosition + 1
+	}
+	if err != nil {
+		return 0, err
+	}
+	if position.Valid {
+		neighbour = position.Float64
+	}
+
+	middle := (anchorPosition + neighbour) / 2
+	if middle == anchorPosition || middle == neighbour {
+		return 0, errNoGap
+	}
+	return middle, nil
+}
+
+// renumberPositions spaces every position out to whole numbers again,
+// keeping the current order.
+func renumberPositions(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id FROM todo ORDER BY position, id;")
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for i, id := range ids {
+		if _, err = tx.Exec("UPDATE todo SET position = ? WHERE id = ?;", i+1, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testDb creates an empty todo database in a temporary directory
+func testDb(t *testing.T) *DbTable {
+	t.Helper()
+	d := &DbTable{dbName: filepath.Join(t.TempDir(), "todo.db"), tableName: "todo"}
+	d.createDB()
+	return d
+}
+
+// Two processes that read the same version of a todo may not both write it,
+// the second must be told about the first rather than overwrite it
+func TestUpdateConflict(t *testing.T) {
+	d := testDb(t)
+	if _, err := d.insertTodos([]todo{{name: "shared", priority: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	other := &DbTable{dbName: d.dbName, tableName: d.tableName}
+
+	first := d.getTodoById(1)
+	second := other.getTodoById(1)
+	if first.version != second.version {
+		t.Fatalf("versions %d and %d of the same todo", first.version, second.version)
+	}
+
+	first.name = "first"
+	if err := d.updateTodoById(1, first); err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	second.name = "second"
+	if err := other.updateTodoById(1, second); err != errConflict {
+		t.Errorf("second update error = %v, want %v", err, errConflict)
+	}
+	if got := d.getTodoById(1); got.name != "first" || got.version != first.version+1 {
+		t.Errorf("todo after the conflict = %q version %d", got.name, got.version)
+	}
+
+	if err := other.updateTodoById(2, second); err == nil || err.Error() != "todo 2 does not exist" {
+		t.Errorf("update of a missing todo error = %v", err)
+	}
+}
+
+func todoNames(todos []todo) []string {
+	var names []string
+	for _, t := range todos {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+// Todos without a due date come after those with one whichever way the dates
+// are sorted, including across pages
+func TestSortUndatedLast(t *testing.T) {
+	d := testDb(t)
+	if _, err := d.insertTodos([]todo{
+		{name: "undated a", priority: 1},
+		{name: "late", priority: 1, due: 2000},
+		{name: "undated b", priority: 1},
+		{name: "early", priority: 1, due: 1000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"sort:due", []string{"early", "late", "undated a", "undated b"}},
+		{"sort:due-", []string{"late", "early", "undated a", "undated b"}},
+	}
+	for _, test := range tests {
+		f, err := parseFilter(test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := todoNames(d.queryTodos(f, -1)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("queryTodos(%q) = %q, want %q", test.expr, got, test.want)
+		}
+		var paged []string
+		for page := 1; page <= 3; page++ {
+			paged = append(paged, todoNames(d.queryPage(f, 1, page))...)
+		}
+		if !reflect.DeepEqual(paged, test.want[:3]) {
+			t.Errorf("queryPage(%q) = %q, want %q", test.expr, paged, test.want[:3])
+		}
+	}
+}
+
+// A context that filters on name cannot be applied once the database is
+// encrypted, which has to be reported rather than match nothing
+func TestScopeEncryptedTextContext(t *testing.T) {
+	d := testDb(t)
+	if _, err := d.insertTodos([]todo{{name: "Globex renewal", priority: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := parseFilter(`name ~ "Globex"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.context = &todoContext{name: "globex", compiled: compiled}
+	if found, err := d.inScope(1); err != nil || !found {
+		t.Errorf("inScope(1) = %v, %v before encrypting", found, err)
+	}
+
+	d.cipher = testCipher(t, "correct horse")
+	if _, err = d.convertTodos(d.cipher.encryptTodo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.inScope(1); err == nil {
+		t.Error("inScope(1) with a name context on an encrypted database succeeded")
+	}
+	if _, err := d.countTodos(&todoFilter{}); err == nil {
+		t.Error("countTodos with a name context on an encrypted database succeeded")
+	}
+}
+
+func todoIds(todos []todo) []int {
+	var ids []int
+	for _, t := range todos {
+		ids = append(ids, t.id)
+	}
+	return ids
+}
+
+// Paging by -page or -after must show every todo exactly once in the order of
+// the whole list, even when most of them tie on the sort keys
+func TestPagesWithTies(t *testing.T) {
+	d := testDb(t)
+	var todos []todo
+	for i := 0; i < 23; i++ {
+		due := []int64{0, 1000, 1000, 2000}[i%4]
+		todos = append(todos, todo{name: []string{"same", "other"}[i%2], priority: Priority(1 + i%2), due: due})
+	}
+	if _, err := d.insertTodos(todos); err != nil {
+		t.Fatal(err)
+	}
+	// Leave only the id to tell most of them apart
+	db, err := d.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("UPDATE todo SET position = 1 WHERE id % 3 != 0;")
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expr := range []string{"", "sort:due", "sort:due-,priority", "sort:name-", "priority = 1 sort:due"} {
+		f, err := parseFilter(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := todoIds(d.queryTodos(f, -1))
+		for _, pageSize := range []int{1, 4, 23, 30} {
+			var paged []int
+			for page := 1; ; page++ {
+				ids := todoIds(d.queryPage(f, pageSize, page))
+				if len(ids) == 0 {
+					break
+				}
+				paged = append(paged, ids...)
+			}
+			if !reflect.DeepEqual(paged, want) {
+				t.Errorf("pages of %d for %q = %v, want %v", pageSize, expr, paged, want)
+			}
+
+			after := todoIds(d.queryPage(f, pageSize, 1))
+			for len(after) > 0 && len(after)%pageSize == 0 {
+				next, err := d.queryAfter(f, pageSize, after[len(after)-1])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(next) == 0 {
+					break
+				}
+				after = append(after, todoIds(next)...)
+			}
+			if !reflect.DeepEqual(after, want) {
+				t.Errorf("pages of %d after the last id for %q = %v, want %v", pageSize, expr, after, want)
+			}
+		}
+	}
+
+	if _, err := d.queryAfter(&todoFilter{}, 5, 99); err == nil || err.Error() != "todo 99 does not exist" {
+		t.Errorf("queryAfter a missing todo error = %v", err)
+	}
+}
+
+// Todos added are counted per week by the database, whatever the limit of
+// the filter
+func TestGetAddedPerWeek(t *testing.T) {
+	d := testDb(t)
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	day := int64(24 * 60 * 60)
+	created := []int64{
+		now.Unix() - 1,         // this week
+		now.Unix() - 6*day,     // this week
+		now.Unix() - 7*day - 1, // last week
+		now.Unix() - 20*day,    // two weeks ago
+		now.Unix() - 21*day,    // too long ago
+		now.Unix(),             // not before now
+		0,                      // before created was recorded
+	}
+	var todos []todo
+	for i := range created {
+		todos = append(todos, todo{name: "t", priority: Priority(1 + i%2)})
+	}
+	todos = append(todos, todo{name: "high", priority: 3})
+	ids, err := d.insertTodos(todos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := d.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range created {
+		if _, err = db.Exec("UPDATE todo SET created = ? WHERE id = ?;", c, ids[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Exec("UPDATE todo SET created = ? WHERE id = ?;", now.Unix()-day, ids[len(ids)-1])
+	db.Close()
+
+	f, err := parseFilter("priority < 3 limit:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.getAddedPerWeek(f, now, 3), []int{2, 1, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("getAddedPerWeek = %v, want %v", got, want)
+	}
+	if got, want := d.getAddedPerWeek(&todoFilter{}, now, 2), []int{3, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("getAddedPerWeek without a filter = %v, want %v", got, want)
+	}
+}
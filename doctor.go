@@ -0,0 +1,349 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type checkStatus int
+
+const (
+	checkOk checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+func (s checkStatus) String() string {
+	switch s {
+	case checkOk:
+		return "ok"
+	case checkWarn:
+		return "warn"
+	default:
+		return "fail"
+	}
+}
+
+// checkResult is a single line of the doctor report. fix is nil when there
+// is no safe automatic repair for the problem.
+type checkResult struct {
+	name    string
+	status  checkStatus
+	message string
+	fix     func() error
+}
+
+// doctor checks the config file and database for problems that would
+// otherwise surface as a panic and optionally repairs the safe ones. It does
+// not use readConfig as the config may be the thing that is broken.
+func doctor(f *flag.FlagSet) {
+	var fix bool
+	f.BoolVar(&fix, "fix", false, "Apply safe repairs for the problems found")
+	f.Parse(os.Args[2:])
+
+	configPath, err := configFilePath()
+	if err != nil {
+		fmt.Println("Error finding home directory: ", err)
+		os.Exit(1)
+	}
+
+	results, config := checkConfig(configPath)
+	d := &DbTable{dbName: config.GetDbName(), tableName: config.GetTableName()}
+	results = append(results, checkDb(d)...)
+
+	failed := 0
+	for _, r := range results {
+		note := ""
+		if r.status != checkOk && r.fix != nil {
+			if fix {
+				if err := r.fix(); err != nil {
+					note = fmt.Sprintf(" (repair failed: %v)", err)
+				} else {
+					note = " (repaired)"
+					r.status = checkOk
+				}
+			} else {
+				note = " (fixable with --fix)"
+			}
+		}
+		if r.status != checkOk {
+			failed++
+		}
+		fmt.Printf("[%-4v] %v: %v%v\n", r.status, r.name, r.message, note)
+	}
+
+	if failed == 0 {
+		fmt.Println("No problems found")
+		return
+	}
+	fmt.Printf("%d problem(s) found\n", failed)
+	os.Exit(1)
+}
+
+// checkConfig validates the config file and returns the config to use for
+// the remaining checks, falling back to defaults where it cannot be read.
+func checkConfig(configPath string) ([]checkResult, *Config) {
+	config := NewConfig()
+	config.ConfigPath = configPath
+	name := "Config file"
+
+	if _, err := os.Stat(configPath); errors.Is(err, os.ErrNotExist) {
+		return []checkResult{{
+			name:    name,
+			status:  checkWarn,
+			message: fmt.Sprintf("%v does not exist, defaults will be used", configPath),
+			fix:     config.WriteConfig,
+		}}, config
+	}
+
+	values, err := readJson(configPath)
+	if err != nil {
+		// Not repaired automatically as it would discard the user's settings
+		return []checkResult{{
+			name:    name,
+			status:  checkFail,
+			message: fmt.Sprintf("%v could not be read: %v. Fix or remove the file", configPath, err),
+		}}, config
+	}
+
+	results := []checkResult{{name: name, status: checkOk, message: configPath}}
+	config, err = ReadConfig(configPath)
+	if err != nil {
+		// readJson succeeded so this should not happen
+		return append(results, checkResult{name: name, status: checkFail, message: err.Error()}), NewConfig()
+	}
+
+	missing := []string{}
+	for _, field := range []string{"ConfigPath", "DbName", "TableName"} {
+		if _, ok := values[field].(string); !ok {
+			missing = append(missing, field)
+		}
+	}
+	if _, ok := values["config"].(map[string]interface{}); !ok {
+		missing = append(missing, "config")
+	}
+	if len(missing) > 0 {
+		results = append(results, checkResult{
+			name:    "Config fields",
+			status:  checkWarn,
+			message: fmt.Sprintf("missing or invalid %v, defaults will be used", strings.Join(missing, ", ")),
+			fix:     config.WriteConfig,
+		})
+	} else {
+		results = append(results, checkResult{name: "Config fields", status: checkOk, message: "all present"})
+	}
+	return results, config
+}
+
+// repairWith opens a new connection for a repair as the checks have closed
+// theirs by the time repairs run
+func repairWith(d *DbTable, repair func(db *sql.DB) error) func() error {
+	return func() error {
+		db, err := sql.Open("sqlite3", "file:"+d.dbName+"?mode=rw&_busy_timeout=5000")
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return repair(db)
+	}
+}
+
+// checkDb validates the database file, table layout and data
+func checkDb(d *DbTable) []checkResult {
+	createDb := func() error {
+		if err := os.MkdirAll(filepath.Dir(d.dbName), 0755); err != nil {
+			return err
+		}
+		_, err := d.createDB()
+		return err
+	}
+
+	if _, err := os.Stat(d.dbName); errors.Is(err, os.ErrNotExist) {
+		return []checkResult{{
+			name:    "Database",
+			status:  checkFail,
+			message: fmt.Sprintf("%v does not exist", d.dbName),
+			fix:     createDb,
+		}}
+	}
+	results := []checkResult{{name: "Database", status: checkOk, message: d.dbName}}
+
+	// mode=rw stops sqlite creating a new empty file in place of a missing one
+	db, err := sql.Open("sqlite3", "file:"+d.dbName+"?mode=rw&_busy_timeout=5000")
+	if err != nil {
+		return append(results, checkResult{name: "Database", status: checkFail, message: err.Error()})
+	}
+	defer db.Close()
+
+	var integrity string
+	if err = db.QueryRow("PRAGMA integrity_check;").Scan(&integrity); err != nil {
+		return append(results, checkResult{name: "Integrity", status: checkFail, message: err.Error()})
+	}
+	if integrity != "ok" {
+		// Nothing safe can be done here, the user should restore a backup
+		return append(results, checkResult{name: "Integrity", status: checkFail, message: integrity})
+	}
+	results = append(results, checkResult{name: "Integrity", status: checkOk, message: "ok"})
+
+	columns, err := d.tableColumns(db)
+	if err != nil {
+		return append(results, checkResult{name: "Schema", status: checkFail, message: err.Error()})
+	}
+	if len(columns) == 0 {
+		return append(results, checkResult{
+			name:    "Schema",
+			status:  checkFail,
+			message: fmt.Sprintf("table %v does not exist", d.tableName),
+			fix:     createDb,
+		})
+	}
+	results = append(results, checkSchema(d, db, columns))
+
+	results = append(results, checkForeignKeys(db))
+	// The data checks rely on the columns existing
+	if _, found := columns["priority"]; found {
+		results = append(results, checkPriorities(d, db))
+	}
+	if _, found := columns["completed"]; found {
+		results = append(results, checkCompleted(d, db))
+	}
+	results = append(results, checkNulls(d, db, columns))
+	return results
+}
+
+func checkSchema(d *DbTable, db *sql.DB, columns map[string]string) checkResult {
+	var missing, mismatched []string
+	for _, c := range todoSchema {
+		colType, found := columns[c.name]
+		if !found {
+			missing = append(missing, c.name)
+		} else if colType != c.sqlType {
+			mismatched = append(mismatched, fmt.Sprintf("%v is %v, expected %v", c.name, colType, c.sqlType))
+		}
+	}
+	if len(mismatched) > 0 {
+		// Changing a column type means rebuilding the table, leave that to the user
+		return checkResult{name: "Schema", status: checkFail, message: strings.Join(mismatched, "; ")}
+	}
+	if len(missing) > 0 {
+		return checkResult{
+			name:    "Schema",
+			status:  checkFail,
+			message: fmt.Sprintf("missing column(s) %v", strings.Join(missing, ", ")),
+			fix:     repairWith(d, func(db *sql.DB) error { return d.addMissingColumns(db) }),
+		}
+	}
+	return checkResult{name: "Schema", status: checkOk, message: fmt.Sprintf("table %v matches", d.tableName)}
+}
+
+// checkForeignKeys reports rows that reference a missing parent row
+func checkForeignKeys(db *sql.DB) checkResult {
+	rows, err := db.Query("PRAGMA foreign_key_check;")
+	if err != nil {
+		return checkResult{name: "Relations", status: checkFail, message: err.Error()}
+	}
+	defer rows.Close()
+	orphans := map[string]int{}
+	for rows.Next() {
+		var table, parent string
+		var rowId sql.NullInt64
+		var fkId int
+		if err = rows.Scan(&table, &rowId, &parent, &fkId); err != nil {
+			return checkResult{name: "Relations", status: checkFail, message: err.Error()}
+		}
+		orphans[fmt.Sprintf("%v -> %v", table, parent)]++
+	}
+	if len(orphans) == 0 {
+		return checkResult{name: "Relations", status: checkOk, message: "no orphaned rows"}
+	}
+	var found []string
+	for relation, count := range orphans {
+		found = append(found, fmt.Sprintf("%v (%d rows)", relation, count))
+	}
+	return checkResult{name: "Relations", status: checkFail, message: "orphaned rows in " + strings.Join(found, ", ")}
+}
+
+// checkPriorities finds priorities outside of the 1 - 3 range accepted by add
+func checkPriorities(d *DbTable, db *sql.DB) checkResult {
+	var count int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE priority IS NULL OR priority < 1 OR priority > 3;", d.tableName)
+	if err := db.QueryRow(query).Scan(&count); err != nil {
+		return checkResult{name: "Priorities", status: checkFail, message: err.Error()}
+	}
+	if count == 0 {
+		return checkResult{name: "Priorities", status: checkOk, message: "all within 1 - 3"}
+	}
+	return checkResult{
+		name:    "Priorities",
+		status:  checkWarn,
+		message: fmt.Sprintf("%d todo(s) with a priority outside 1 - 3", count),
+		fix: repairWith(d, func(db *sql.DB) error {
+			_, err := db.Exec(fmt.Sprintf(`UPDATE %v SET priority = CASE
+				WHEN priority IS NULL OR priority < 1 THEN 1
+				ELSE 3 END
+				WHERE priority IS NULL OR priority < 1 OR priority > 3;`, d.tableName))
+			return err
+		}),
+	}
+}
+
+// checkCompleted finds completed flags that are neither 0 nor 1
+func checkCompleted(d *DbTable, db *sql.DB) checkResult {
+	var count int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE completed IS NULL OR completed NOT IN (0, 1);", d.tableName)
+	if err := db.QueryRow(query).Scan(&count); err != nil {
+		return checkResult{name: "Status", status: checkFail, message: err.Error()}
+	}
+	if count == 0 {
+		return checkResult{name: "Status", status: checkOk, message: "all complete or incomplete"}
+	}
+	return checkResult{
+		name:    "Status",
+		status:  checkWarn,
+		message: fmt.Sprintf("%d todo(s) with an invalid completed value", count),
+		fix: repairWith(d, func(db *sql.DB) error {
+			_, err := db.Exec(fmt.Sprintf(`UPDATE %v SET completed = CASE
+				WHEN completed IS NULL OR completed = 0 THEN 0
+				ELSE 1 END
+				WHERE completed IS NULL OR completed NOT IN (0, 1);`, d.tableName))
+			return err
+		}),
+	}
+}
+
+// checkNulls finds NULL text values, which cannot be scanned into a todo
+func checkNulls(d *DbTable, db *sql.DB, columns map[string]string) checkResult {
+	var conditions, updates []string
+	for _, c := range todoSchema {
+		if _, found := columns[c.name]; found && c.sqlType == "TEXT" {
+			conditions = append(conditions, c.name+" IS NULL")
+			updates = append(updates, fmt.Sprintf("%v = COALESCE(%v, '')", c.name, c.name))
+		}
+	}
+	if len(conditions) == 0 {
+		return checkResult{name: "Empty values", status: checkOk, message: "no text columns to check"}
+	}
+	where := strings.Join(conditions, " OR ")
+
+	var count int
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE %v;", d.tableName, where)).Scan(&count); err != nil {
+		return checkResult{name: "Empty values", status: checkFail, message: err.Error()}
+	}
+	if count == 0 {
+		return checkResult{name: "Empty values", status: checkOk, message: "no NULL text values"}
+	}
+	return checkResult{
+		name:    "Empty values",
+		status:  checkWarn,
+		message: fmt.Sprintf("%d todo(s) with NULL text values", count),
+		fix: repairWith(d, func(db *sql.DB) error {
+			_, err := db.Exec(fmt.Sprintf("UPDATE %v SET %v WHERE %v;", d.tableName, strings.Join(updates, ", "), where))
+			return err
+		}),
+	}
+}
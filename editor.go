@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// A todo is edited as a header of fields between --- lines followed by
+// the content, e.g.
+//
+//	---
+//	name: Fix login bug
+//	priority: 2
+//	status: incomplete
+//	due: 2026-11-01
+//	tags: backend urgent
+//	context: office
+//	---
+//	Content of the todo, as many lines as needed.
+
+const frontMatter = "---"
+
+var errEditAborted = errors.New("aborted")
+
+// editorCommand returns the user's editor and its arguments
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(env)); len(editor) > 0 {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+func formatTodoFile(t todo) string {
+	var b strings.Builder
+	fmt.Fprintln(&b, frontMatter)
+	fmt.Fprintln(&b, "# Save and close to continue, leave unchanged or empty the file to abort")
+	fmt.Fprintf(&b, "name: %v\n", t.name)
+	fmt.Fprintf(&b, "priority: %d\n", t.priority)
+	status := "incomplete"
+	if t.completed == 1 {
+		status = "complete"
+	}
+	fmt.Fprintf(&b, "status: %v\n", status)
+	due := ""
+	if d, hasDue := t.dueTime(); hasDue {
+		due = formatDate(d)
+	}
+	fmt.Fprintf(&b, "due: %v\n", due)
+	fmt.Fprintf(&b, "tags: %v\n", joinTags(t.tags))
+	fmt.Fprintf(&b, "context: %v\n", t.context)
+	fmt.Fprintln(&b, frontMatter)
+	fmt.Fprint(&b, t.content)
+	if t.content != "" && !strings.HasSuffix(t.content, "\n") {
+		fmt.Fprintln(&b)
+	}
+	return b.String()
+}
+
+// parseTodoFile reads an edited file back onto a copy of t
+func parseTodoFile(text string, t todo) (todo, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != frontMatter {
+		return t, fmt.Errorf("line 1: expected %v to start the header", frontMatter)
+	}
+
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == frontMatter {
+			end = i
+			break
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return t, fmt.Errorf("line %d: expected <field>: <value>", i+1)
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "name":
+			t.name = value
+		case "priority":
+			p, err := strconv.Atoi(value)
+			if err != nil || p < 1 || p > 3 {
+				return t, fmt.Errorf("line %d: priority must be 1, 2 or 3", i+1)
+			}
+			t.priority = Priority(p)
+		case "status":
+			switch value {
+			case "incomplete":
+				t.completed = 0
+			case "complete":
+				t.completed = 1
+			default:
+				return t, fmt.Errorf("line %d: status must be incomplete or complete", i+1)
+			}
+		case "due":
+			if value == "" {
+				t.due = 0
+				continue
+			}
+			due, err := parseDate(value)
+			if err != nil {
+				return t, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			t.due = due.Unix()
+		case "tags":
+			tags, err := parseTagList(value)
+			if err != nil {
+				return t, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			t.tags = tags
+		case "context":
+			context, err := parseContextName(value)
+			if err != nil {
+				return t, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			t.context = context
+		default:
+			return t, fmt.Errorf("line %d: unknown field %q", i+1, key)
+		}
+	}
+	if end == -1 {
+		return t, fmt.Errorf("expected %v to end the header", frontMatter)
+	}
+	if t.name == "" {
+		return t, errors.New("name must not be empty")
+	}
+	t.content = strings.TrimRight(strings.Join(lines[end+1:], "\n"), "\n")
+	return t, nil
+}
+
+// editTodo opens t in the user's editor until it parses or the user gives
+// up, returning errEditAborted if the file is left unchanged or emptied
+func editTodo(t todo) (todo, error) {
+	file, err := os.CreateTemp("", "todo-*.md")
+	if err != nil {
+		return t, err
+	}
+	defer os.Remove(file.Name())
+
+	original := formatTodoFile(t)
+	text := original
+	for {
+		if err = os.WriteFile(file.Name(), []byte(text), 0600); err != nil {
+			return t, err
+		}
+		editor := editorCommand()
+		cmd := exec.Command(editor[0], append(editor[1:], file.Name())...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err = cmd.Run(); err != nil {
+			return t, fmt.Errorf("running %v: %v", strings.Join(editor, " "), err)
+		}
+
+		edited, err := os.ReadFile(file.Name())
+		if err != nil {
+			return t, err
+		}
+		if len(bytes.TrimSpace(edited)) == 0 || string(edited) == original {
+			return t, errEditAborted
+		}
+
+		result, err := parseTodoFile(string(edited), t)
+		if err == nil {
+			return result, nil
+		}
+		fmt.Println("Error in todo: ", err)
+		fmt.Print("Edit again? [Y/n] ")
+		var answer string
+		fmt.Scanln(&answer)
+		if strings.HasPrefix(strings.ToLower(answer), "n") {
+			return t, errEditAborted
+		}
+		text = string(edited)
+	}
+}
+
+// printTodoDiff shows the fields that differ between two versions of a todo
+func printTodoDiff(before todo, after todo) bool {
+	changed := false
+	field := func(name string, old string, new string) {
+		if old != new {
+			fmt.Printf("  %-9v %q -> %q\n", name+":", old, new)
+			changed = true
+		}
+	}
+	dueText := func(t todo) string {
+		if d, hasDue := t.dueTime(); hasDue {
+			return formatDate(d)
+		}
+		return ""
+	}
+	field("name", before.name, after.name)
+	field("priority", strconv.Itoa(int(before.priority)), strconv.Itoa(int(after.priority)))
+	field("completed", strconv.Itoa(before.completed), strconv.Itoa(after.completed))
+	field("due", dueText(before), dueText(after))
+	field("tags", joinTags(before.tags), joinTags(after.tags))
+	field("context", before.context, after.context)
+	if before.content != after.content {
+		fmt.Println("  content:")
+		printLines := func(prefix string, content string) {
+			if content == "" {
+				return
+			}
+			for _, line := range strings.Split(content, "\n") {
+				fmt.Println(prefix + line)
+			}
+		}
+		printLines("  - ", before.content)
+		printLines("  + ", after.content)
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,592 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A filter expression selects todos for list, for example
+//
+//	priority >= 2 and (name ~ "deploy" or content ~ "rollback") and not completed sort:due limit:5
+//
+// It is compiled to a parameterised WHERE clause; user input only ever
+// reaches the database as a query argument.
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenClause // sort:... or limit:...
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	pos   int // byte offset into the expression
+	value string
+}
+
+// filterError points at the token that could not be understood
+type filterError struct {
+	expr string
+	pos  int
+	msg  string
+}
+
+func (e *filterError) Error() string {
+	return fmt.Sprintf("%v\n  %v\n  %v^", e.msg, e.expr, strings.Repeat(" ", e.pos))
+}
+
+type fieldKind int
+
+const (
+	fieldInt fieldKind = iota
+	fieldText
+	fieldBool
+	fieldDate
+	fieldLabel // text that is never encrypted
+	fieldTags
+)
+
+type filterField struct {
+	column string
+	kind   fieldKind
+}
+
+// filterFields are the fields that can be used in an expression. Only these
+// column names are ever written into the SQL.
+var filterFields = map[string]filterField{
+	"id":        {"id", fieldInt},
+	"name":      {"name", fieldText},
+	"content":   {"content", fieldText},
+	"priority":  {"priority", fieldInt},
+	"completed": {"completed", fieldBool},
+	"due":       {"due", fieldDate},
+	"created":   {"created", fieldDate},
+	// completed_at is only known for todos completed since it was added
+	"completed_at": {"completed_at", fieldDate},
+	"tags":         {"tags", fieldTags},
+	"context":      {"context", fieldLabel},
+}
+
+// sortUrgency is not a column, todos are sorted by it after they are read
+const sortUrgency = "urgency"
+
+type sortKey struct {
+	field      string
+	descending bool
+}
+
+// todoFilter is a compiled filter expression
+type todoFilter struct {
+	where string
+	args  []interface{}
+	sort  []sortKey
+	limit int  // 0 when the expression has no limit: clause
+	text  bool // name or content are read, see usesText
+}
+
+func lexFilter(expr string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(expr) {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			var value strings.Builder
+			i++
+			for i < len(expr) && rune(expr[i]) != c {
+				if expr[i] == '\\' && i+1 < len(expr) {
+					i++
+				}
+				value.WriteByte(expr[i])
+				i++
+			}
+			if i >= len(expr) {
+				return nil, &filterError{expr, start, "unterminated string"}
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: expr[start:i], pos: start, value: value.String()})
+		case strings.ContainsRune("=!<>~", c):
+			start := i
+			op := string(c)
+			if i+1 < len(expr) && (expr[i+1] == '=' || (c == '!' && expr[i+1] == '~')) {
+				op = expr[i : i+2]
+			}
+			i += len(op)
+			if op == "==" {
+				op = "="
+			}
+			if op == "!" {
+				return nil, &filterError{expr, start, "unexpected '!', use 'not'"}
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: start, value: op})
+		case unicode.IsDigit(c) || c == '-' || c == '+':
+			start := i
+			i++
+			for i < len(expr) && !unicode.IsSpace(rune(expr[i])) && !strings.ContainsRune("()=!<>~", rune(expr[i])) {
+				i++
+			}
+			text := expr[start:i]
+			kind := tokenNumber
+			if _, err := strconv.Atoi(text); err != nil {
+				// Dates such as 2026-11-01 are passed on as words
+				kind = tokenIdent
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: start, value: text})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(expr) && (unicode.IsLetter(rune(expr[i])) || unicode.IsDigit(rune(expr[i])) || expr[i] == '_' || expr[i] == '.') {
+				i++
+			}
+			word := expr[start:i]
+			if i < len(expr) && expr[i] == ':' {
+				// sort:due,priority- and limit:5 run to the next space
+				i++
+				valueStart := i
+				for i < len(expr) && !unicode.IsSpace(rune(expr[i])) {
+					i++
+				}
+				tokens = append(tokens, token{kind: tokenClause, text: expr[start:i], pos: start, value: expr[valueStart:i]})
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: word, pos: start, value: word})
+		default:
+			r, _ := utf8.DecodeRuneInString(expr[i:])
+			return nil, &filterError{expr, i, fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(expr)}), nil
+}
+
+type filterParser struct {
+	expr   string
+	tokens []token
+	pos    int
+	args   []interface{}
+	text   bool
+}
+
+func (p *filterParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *filterParser) errorAt(t token, format string, a ...interface{}) error {
+	return &filterError{p.expr, t.pos, fmt.Sprintf(format, a...)}
+}
+
+func isKeyword(t token, keyword string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.value, keyword)
+}
+
+// parseFilter compiles a filter expression. An empty expression matches
+// every todo.
+func parseFilter(expr string) (*todoFilter, error) {
+	tokens, err := lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{expr: expr}
+	filter := &todoFilter{}
+
+	// Clauses may appear anywhere, take them out before parsing the rest
+	for _, t := range tokens {
+		if t.kind != tokenClause {
+			p.tokens = append(p.tokens, t)
+			continue
+		}
+		if err = p.parseClause(t, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek().kind == tokenEOF {
+		return filter, nil
+	}
+	filter.where, err = p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorAt(t, "unexpected %q, expected 'and', 'or' or the end of the filter", t.text)
+	}
+	filter.args = p.args
+	filter.text = filter.text || p.text
+	return filter, nil
+}
+
+func (p *filterParser) parseClause(t token, filter *todoFilter) error {
+	name := strings.ToLower(t.text[:len(t.text)-len(t.value)-1])
+	switch name {
+	case "limit":
+		limit, err := strconv.Atoi(t.value)
+		if err != nil || limit < 1 {
+			return p.errorAt(t, "limit must be a positive number")
+		}
+		filter.limit = limit
+	case "sort":
+		if t.value == "" {
+			return p.errorAt(t, "sort needs at least one field")
+		}
+		for _, key := range strings.Split(t.value, ",") {
+			s := sortKey{field: strings.TrimRight(key, "+-"), descending: strings.HasSuffix(key, "-")}
+			if _, found := filterFields[s.field]; !found && s.field != sortUrgency {
+				return p.errorAt(t, "unknown sort field %q", s.field)
+			}
+			if filterFields[s.field].kind == fieldText {
+				filter.text = true
+			}
+			if len(filter.sort) > 0 && (s.field == sortUrgency || filter.sort[0].field == sortUrgency) {
+				return p.errorAt(t, "urgency cannot be combined with other sort fields")
+			}
+			filter.sort = append(filter.sort, s)
+		}
+	default:
+		return p.errorAt(t, "unknown clause %q, expected sort: or limit:", name)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr() (string, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+	for isKeyword(p.peek(), "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		left = fmt.Sprintf("(%v OR %v)", left, right)
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (string, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return "", err
+	}
+	for isKeyword(p.peek(), "and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return "", err
+		}
+		left = fmt.Sprintf("(%v AND %v)", left, right)
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (string, error) {
+	if isKeyword(p.peek(), "not") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(NOT %v)", inner), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return "", p.errorAt(closing, "expected ')'")
+		}
+		return inner, nil
+	case tokenIdent:
+		field, found := filterFields[strings.ToLower(t.value)]
+		if !found {
+			return "", p.errorAt(t, "unknown field %q, expected one of %v", t.value, filterFieldNames())
+		}
+		if p.peek().kind != tokenOp {
+			// A bare field tests that it is set
+			switch field.kind {
+			case fieldBool:
+				return fmt.Sprintf("%v = 1", field.column), nil
+			case fieldDate:
+				return fmt.Sprintf("%v != 0", field.column), nil
+			case fieldLabel, fieldTags:
+				return fmt.Sprintf("%v != ''", field.column), nil
+			}
+			return "", p.errorAt(p.peek(), "expected a comparison after %q", t.value)
+		}
+		return p.parseComparison(t, field)
+	case tokenEOF:
+		return "", p.errorAt(t, "unexpected end of filter")
+	}
+	return "", p.errorAt(t, "unexpected %q, expected a field", t.text)
+}
+
+func (p *filterParser) parseComparison(fieldToken token, field filterField) (string, error) {
+	opToken := p.next()
+	op := opToken.value
+	valueToken := p.next()
+	if valueToken.kind != tokenString && valueToken.kind != tokenNumber && valueToken.kind != tokenIdent {
+		return "", p.errorAt(valueToken, "expected a value after %q", op)
+	}
+	value := valueToken.value
+
+	switch field.kind {
+	case fieldText, fieldLabel:
+		if field.kind == fieldText {
+			p.text = true
+		}
+		switch op {
+		case "=", "!=":
+			p.args = append(p.args, value)
+			return fmt.Sprintf("%v %v ?", field.column, op), nil
+		case "~", "!~":
+			// Case insensitive substring match
+			p.args = append(p.args, "%"+escapeLike(value)+"%")
+			not := ""
+			if op == "!~" {
+				not = "NOT "
+			}
+			return fmt.Sprintf("%v %vLIKE ? ESCAPE '\\'", field.column, not), nil
+		}
+		return "", p.errorAt(opToken, "%v can only be compared with =, !=, ~ or !~", fieldToken.text)
+
+	case fieldTags:
+		switch op {
+		case "=", "!=":
+			// Has the tag, matching whole words of the space separated list
+			p.args = append(p.args, "% "+escapeLike(value)+" %")
+			not := ""
+			if op == "!=" {
+				not = "NOT "
+			}
+			return fmt.Sprintf("(' ' || %v || ' ') %vLIKE ? ESCAPE '\\'", field.column, not), nil
+		case "~", "!~":
+			p.args = append(p.args, "%"+escapeLike(value)+"%")
+			not := ""
+			if op == "!~" {
+				not = "NOT "
+			}
+			return fmt.Sprintf("%v %vLIKE ? ESCAPE '\\'", field.column, not), nil
+		}
+		return "", p.errorAt(opToken, "tags can only be compared with = (has tag), !=, ~ or !~")
+
+	case fieldBool:
+		if op != "=" && op != "!=" {
+			return "", p.errorAt(opToken, "%v can only be compared with = or !=", fieldToken.text)
+		}
+		var b int
+		switch strings.ToLower(value) {
+		case "true", "yes", "1":
+			b = 1
+		case "false", "no", "0":
+			b = 0
+		default:
+			return "", p.errorAt(valueToken, "expected true or false for %v", fieldToken.text)
+		}
+		p.args = append(p.args, b)
+		return fmt.Sprintf("%v %v ?", field.column, op), nil
+
+	case fieldInt:
+		if op == "~" || op == "!~" {
+			return "", p.errorAt(opToken, "%v is a number, use =, !=, <, <=, > or >=", fieldToken.text)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", p.errorAt(valueToken, "expected a number for %v", fieldToken.text)
+		}
+		p.args = append(p.args, n)
+		return fmt.Sprintf("%v %v ?", field.column, op), nil
+
+	case fieldDate:
+		if op == "~" || op == "!~" {
+			return "", p.errorAt(opToken, "%v is a date, use =, !=, <, <=, > or >=", fieldToken.text)
+		}
+		date, err := parseDate(value)
+		if err != nil {
+			return "", p.errorAt(valueToken, "%v", err)
+		}
+		return p.dateComparison(field.column, op, date), nil
+	}
+	return "", p.errorAt(fieldToken, "cannot compare %v", fieldToken.text)
+}
+
+// dateComparison compares a unix seconds column with a date. A date without
+// a time of day stands for the whole day. Todos without the date set never
+// match.
+func (p *filterParser) dateComparison(column string, op string, date time.Time) string {
+	start, end := date, date
+	if date.Hour() == 0 && date.Minute() == 0 && date.Second() == 0 {
+		end = date.AddDate(0, 0, 1)
+	} else {
+		end = date.Add(time.Second)
+	}
+
+	var condition string
+	switch op {
+	case "<":
+		condition = fmt.Sprintf("%v < ?", column)
+		p.args = append(p.args, start.Unix())
+	case "<=":
+		condition = fmt.Sprintf("%v < ?", column)
+		p.args = append(p.args, end.Unix())
+	case ">":
+		condition = fmt.Sprintf("%v >= ?", column)
+		p.args = append(p.args, end.Unix())
+	case ">=":
+		condition = fmt.Sprintf("%v >= ?", column)
+		p.args = append(p.args, start.Unix())
+	case "=":
+		condition = fmt.Sprintf("%v >= ? AND %v < ?", column, column)
+		p.args = append(p.args, start.Unix(), end.Unix())
+	default:
+		condition = fmt.Sprintf("NOT (%v >= ? AND %v < ?)", column, column)
+		p.args = append(p.args, start.Unix(), end.Unix())
+	}
+	return fmt.Sprintf("(%v != 0 AND %v)", column, condition)
+}
+
+// escapeLike escapes the LIKE wildcards in value, for use with ESCAPE '\'
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+func filterFieldNames() string {
+	var names []string
+	for name := range filterFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// orderKey is one column or expression of the ORDER BY clause
+type orderKey struct {
+	column     string
+	descending bool
+}
+
+// orderKeys are the columns todos are sorted by, falling back to the default
+// list order. Manual order and then id break any remaining ties so that the
+// order is total, which keyset pagination relies on. An unset date is stored
+// as 0, so dates are first ordered by whether they are unset to put todos
+// without one last in either direction.
+func (f *todoFilter) orderKeys() []orderKey {
+	if len(f.sort) == 0 || f.sort[0].field == sortUrgency {
+		return []orderKey{{"completed", false}, {"priority", true}, {"position", false}, {"id", false}}
+	}
+	var keys []orderKey
+	for _, s := range f.sort {
+		field := filterFields[s.field]
+		if field.kind == fieldDate {
+			keys = append(keys, orderKey{"(" + field.column + " = 0)", false})
+		}
+		keys = append(keys, orderKey{field.column, s.descending})
+	}
+	return append(keys, orderKey{"position", false}, orderKey{"id", false})
+}
+
+// orderBy builds the ORDER BY clause
+func (f *todoFilter) orderBy() string {
+	var keys []string
+	for _, k := range f.orderKeys() {
+		key := k.column
+		if k.descending {
+			key += " DESC"
+		}
+		keys = append(keys, key)
+	}
+	return strings.Join(keys, ", ")
+}
+
+// after limits the filter to rows sorted after cursor, the values of
+// orderKeys for the last row of the previous page
+func (f *todoFilter) after(cursor []interface{}) {
+	keys := f.orderKeys()
+	var alternatives []string
+	var args []interface{}
+	for i, k := range keys {
+		var conditions []string
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, keys[j].column+" = ?")
+			args = append(args, cursor[j])
+		}
+		op := ">"
+		if k.descending {
+			op = "<"
+		}
+		conditions = append(conditions, k.column+" "+op+" ?")
+		args = append(args, cursor[i])
+		alternatives = append(alternatives, "("+strings.Join(conditions, " AND ")+")")
+	}
+	f.and(strings.Join(alternatives, " OR "), args...)
+}
+
+func (f *todoFilter) sortsByUrgency() bool {
+	return len(f.sort) > 0 && f.sort[0].field == sortUrgency
+}
+
+// usesText reports whether the filter reads name or content, which cannot
+// be done by the database when they are encrypted
+func (f *todoFilter) usesText() bool {
+	return f.text
+}
+
+// and combines the filter with another condition
+func (f *todoFilter) and(condition string, args ...interface{}) {
+	if f.where == "" {
+		f.where = condition
+	} else {
+		f.where = fmt.Sprintf("(%v) AND (%v)", condition, f.where)
+		args = append(args, f.args...)
+	}
+	f.args = args
+}
+
+// merge adds the conditions of a saved filter. Sort and limit clauses given
+// on the command line take precedence over saved ones.
+func (f *todoFilter) merge(saved *todoFilter) {
+	if saved.where != "" {
+		f.and(saved.where, saved.args...)
+	}
+	if len(f.sort) == 0 {
+		f.sort = saved.sort
+	}
+	if f.limit == 0 {
+		f.limit = saved.limit
+	}
+	f.text = f.text || saved.text
+}
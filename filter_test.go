@@ -0,0 +1,275 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLexFilter(t *testing.T) {
+	tests := []struct {
+		expr  string
+		kinds []tokenKind
+		texts []string
+	}{
+		{"priority >= 2", []tokenKind{tokenIdent, tokenOp, tokenNumber, tokenEOF}, []string{"priority", ">=", "2", ""}},
+		{`name~"a b"`, []tokenKind{tokenIdent, tokenOp, tokenString, tokenEOF}, []string{"name", "~", `"a b"`, ""}},
+		{"(tags != x)", []tokenKind{tokenLParen, tokenIdent, tokenOp, tokenIdent, tokenRParen, tokenEOF}, []string{"(", "tags", "!=", "x", ")", ""}},
+		{"name !~ 'it''s'", []tokenKind{tokenIdent, tokenOp, tokenString, tokenString, tokenEOF}, []string{"name", "!~", "'it'", "'s'", ""}},
+		{"due == 2026-11-01", []tokenKind{tokenIdent, tokenOp, tokenIdent, tokenEOF}, []string{"due", "=", "2026-11-01", ""}},
+		{"due < -1d sort:due,priority- limit:5", []tokenKind{tokenIdent, tokenOp, tokenIdent, tokenClause, tokenClause, tokenEOF}, []string{"due", "<", "-1d", "sort:due,priority-", "limit:5", ""}},
+	}
+	for _, test := range tests {
+		tokens, err := lexFilter(test.expr)
+		if err != nil {
+			t.Errorf("lexFilter(%q) error: %v", test.expr, err)
+			continue
+		}
+		var kinds []tokenKind
+		var texts []string
+		for _, token := range tokens {
+			kinds = append(kinds, token.kind)
+			texts = append(texts, token.text)
+		}
+		if !reflect.DeepEqual(kinds, test.kinds) || !reflect.DeepEqual(texts, test.texts) {
+			t.Errorf("lexFilter(%q) = %v %q, want %v %q", test.expr, kinds, texts, test.kinds, test.texts)
+		}
+	}
+}
+
+func TestLexFilterStrings(t *testing.T) {
+	tokens, err := lexFilter(`name = "say \"hi\"" and content = 'a\\b'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens[2].value != `say "hi"` || tokens[6].value != `a\b` {
+		t.Errorf("string values = %q and %q", tokens[2].value, tokens[6].value)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	nov1 := time.Date(2026, time.November, 1, 0, 0, 0, 0, time.Local)
+	nov2 := nov1.AddDate(0, 0, 1)
+	afternoon := time.Date(2026, time.November, 1, 14, 0, 0, 0, time.Local)
+	tests := []struct {
+		expr  string
+		where string
+		args  []interface{}
+		text  bool
+	}{
+		{"", "", nil, false},
+		{"priority >= 2", "priority >= ?", []interface{}{2}, false},
+		{"ID = 3", "id = ?", []interface{}{3}, false},
+		{`name ~ "deploy"`, `name LIKE ? ESCAPE '\'`, []interface{}{"%deploy%"}, true},
+		{`content !~ "50%_off"`, `content NOT LIKE ? ESCAPE '\'`, []interface{}{`%50\%\_off%`}, true},
+		{"name = x", "name = ?", []interface{}{"x"}, true},
+		{"context = home", "context = ?", []interface{}{"home"}, false},
+		{"context ~ ho", `context LIKE ? ESCAPE '\'`, []interface{}{"%ho%"}, false},
+		{"tags = work", `(' ' || tags || ' ') LIKE ? ESCAPE '\'`, []interface{}{"% work %"}, false},
+		{"tags != a_b", `(' ' || tags || ' ') NOT LIKE ? ESCAPE '\'`, []interface{}{`% a\_b %`}, false},
+		{"tags ~ wo", `tags LIKE ? ESCAPE '\'`, []interface{}{"%wo%"}, false},
+		{"tags", "tags != ''", nil, false},
+		{"context", "context != ''", nil, false},
+		{"due", "due != 0", nil, false},
+		{"completed", "completed = 1", nil, false},
+		{"not completed", "(NOT completed = 1)", nil, false},
+		{"completed = no", "completed = ?", []interface{}{0}, false},
+		{"completed != TRUE", "completed != ?", []interface{}{1}, false},
+		{"not not completed", "(NOT (NOT completed = 1))", nil, false},
+		{"priority = 1 or priority = 2 and completed", "(priority = ? OR (priority = ? AND completed = 1))", []interface{}{1, 2}, false},
+		{"(priority = 1 or priority = 2) and completed", "((priority = ? OR priority = ?) AND completed = 1)", []interface{}{1, 2}, false},
+		{"priority = 1 AND priority = 2 Or id = 3", "((priority = ? AND priority = ?) OR id = ?)", []interface{}{1, 2, 3}, false},
+		{"due < 2026-11-01", "(due != 0 AND due < ?)", []interface{}{nov1.Unix()}, false},
+		{"due <= 2026-11-01", "(due != 0 AND due < ?)", []interface{}{nov2.Unix()}, false},
+		{"due > 2026-11-01", "(due != 0 AND due >= ?)", []interface{}{nov2.Unix()}, false},
+		{"due >= 2026-11-01", "(due != 0 AND due >= ?)", []interface{}{nov1.Unix()}, false},
+		{"due = 2026-11-01", "(due != 0 AND due >= ? AND due < ?)", []interface{}{nov1.Unix(), nov2.Unix()}, false},
+		{"created != 2026-11-01", "(created != 0 AND NOT (created >= ? AND created < ?))", []interface{}{nov1.Unix(), nov2.Unix()}, false},
+		{`due > "2026-11-01 14:00"`, "(due != 0 AND due >= ?)", []interface{}{afternoon.Add(time.Second).Unix()}, false},
+		{`completed_at = "2026-11-01 14:00"`, "(completed_at != 0 AND completed_at >= ? AND completed_at < ?)", []interface{}{afternoon.Unix(), afternoon.Add(time.Second).Unix()}, false},
+		{"sort:name", "", nil, true},
+		{"priority = 3 sort:due limit:2", "priority = ?", []interface{}{3}, false},
+	}
+	for _, test := range tests {
+		f, err := parseFilter(test.expr)
+		if err != nil {
+			t.Errorf("parseFilter(%q) error: %v", test.expr, err)
+			continue
+		}
+		if f.where != test.where || !reflect.DeepEqual(f.args, test.args) || f.usesText() != test.text {
+			t.Errorf("parseFilter(%q) = %q %v text %v, want %q %v text %v",
+				test.expr, f.where, f.args, f.usesText(), test.where, test.args, test.text)
+		}
+	}
+}
+
+func TestParseFilterClauses(t *testing.T) {
+	tests := []struct {
+		expr  string
+		sort  []sortKey
+		limit int
+	}{
+		{"limit:5", nil, 5},
+		{"sort:due", []sortKey{{"due", false}}, 0},
+		{"sort:due,priority- limit:3", []sortKey{{"due", false}, {"priority", true}}, 3},
+		{"limit:3 priority = 1 sort:created+", []sortKey{{"created", false}}, 3},
+		{"sort:urgency", []sortKey{{sortUrgency, false}}, 0},
+	}
+	for _, test := range tests {
+		f, err := parseFilter(test.expr)
+		if err != nil {
+			t.Errorf("parseFilter(%q) error: %v", test.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(f.sort, test.sort) || f.limit != test.limit {
+			t.Errorf("parseFilter(%q) sort %v limit %v, want %v %v", test.expr, f.sort, f.limit, test.sort, test.limit)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		msg  string
+		pos  int
+	}{
+		{"foo = 1", `unknown field "foo"`, 0},
+		{"priority ~ 1", "priority is a number", 9},
+		{"priority = high", "expected a number for priority", 11},
+		{"due ~ 2026", "due is a date", 4},
+		{"due < someday", `unrecognised date "someday"`, 6},
+		{"completed = maybe", "expected true or false for completed", 12},
+		{"completed < 1", "completed can only be compared with = or !=", 10},
+		{"name > x", "name can only be compared with =, !=, ~ or !~", 5},
+		{"tags < x", "tags can only be compared with = (has tag)", 5},
+		{"priority", `expected a comparison after "priority"`, 8},
+		{"priority =", `expected a value after "="`, 10},
+		{"(priority = 1", "expected ')'", 13},
+		{"priority = 1 completed", `unexpected "completed", expected 'and', 'or' or the end of the filter`, 13},
+		{"priority = 1 and", "unexpected end of filter", 16},
+		{"= 1", `unexpected "=", expected a field`, 0},
+		{`name = "open`, "unterminated string", 7},
+		{"! completed", "unexpected '!', use 'not'", 0},
+		{"name = x;", "unexpected character ';'", 8},
+		{"limit:0", "limit must be a positive number", 0},
+		{"limit:many", "limit must be a positive number", 0},
+		{"sort:", "sort needs at least one field", 0},
+		{"sort:size", `unknown sort field "size"`, 0},
+		{"sort:urgency,due", "urgency cannot be combined with other sort fields", 0},
+		{"sort:due,urgency", "urgency cannot be combined with other sort fields", 0},
+		{"group:tags", `unknown clause "group"`, 0},
+	}
+	for _, test := range tests {
+		_, err := parseFilter(test.expr)
+		fe, ok := err.(*filterError)
+		if !ok {
+			t.Errorf("parseFilter(%q) error = %v, want a filterError", test.expr, err)
+			continue
+		}
+		if !strings.HasPrefix(fe.msg, test.msg) || fe.pos != test.pos {
+			t.Errorf("parseFilter(%q) error %q at %d, want %q at %d", test.expr, fe.msg, fe.pos, test.msg, test.pos)
+		}
+	}
+}
+
+func TestFilterErrorPointsAtToken(t *testing.T) {
+	_, err := parseFilter("priority ~ 1")
+	want := "priority is a number, use =, !=, <, <=, > or >=\n  priority ~ 1\n           ^"
+	if err == nil || err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestFilterAnd(t *testing.T) {
+	f, err := parseFilter("priority = 2 or tags = x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.and("completed = ?", 0)
+	f.and("context = ?", "work")
+	where := `(context = ?) AND ((completed = ?) AND ((priority = ? OR (' ' || tags || ' ') LIKE ? ESCAPE '\')))`
+	args := []interface{}{"work", 0, 2, "% x %"}
+	if f.where != where || !reflect.DeepEqual(f.args, args) {
+		t.Errorf("and = %q %v, want %q %v", f.where, f.args, where, args)
+	}
+
+	empty := &todoFilter{}
+	empty.and("id = ?", 1)
+	if empty.where != "id = ?" || !reflect.DeepEqual(empty.args, []interface{}{1}) {
+		t.Errorf("and on an empty filter = %q %v", empty.where, empty.args)
+	}
+}
+
+func TestFilterMerge(t *testing.T) {
+	saved, _ := parseFilter("name ~ x sort:due limit:4")
+	f, _ := parseFilter("priority = 3 limit:2")
+	f.merge(saved)
+	where := `(name LIKE ? ESCAPE '\') AND (priority = ?)`
+	if f.where != where || !reflect.DeepEqual(f.args, []interface{}{"%x%", 3}) {
+		t.Errorf("merge = %q %v, want %q", f.where, f.args, where)
+	}
+	if !reflect.DeepEqual(f.sort, []sortKey{{"due", false}}) || f.limit != 2 || !f.usesText() {
+		t.Errorf("merge sort %v limit %v text %v, want the saved sort, limit 2 and text", f.sort, f.limit, f.usesText())
+	}
+}
+
+func TestOrderKeys(t *testing.T) {
+	tests := []struct {
+		expr    string
+		orderBy string
+	}{
+		{"", "completed, priority DESC, position, id"},
+		{"sort:urgency", "completed, priority DESC, position, id"},
+		{"sort:due", "(due = 0), due, position, id"},
+		{"sort:due-", "(due = 0), due DESC, position, id"},
+		{"sort:due,priority-", "(due = 0), due, priority DESC, position, id"},
+		{"sort:completed_at-,name", "(completed_at = 0), completed_at DESC, name, position, id"},
+	}
+	for _, test := range tests {
+		f, err := parseFilter(test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.orderBy(); got != test.orderBy {
+			t.Errorf("orderBy(%q) = %q, want %q", test.expr, got, test.orderBy)
+		}
+	}
+}
+
+func TestFilterAfter(t *testing.T) {
+	tests := []struct {
+		expr   string
+		cursor []interface{}
+		where  string
+		args   []interface{}
+	}{
+		{"", []interface{}{0, 3, 1.5, 7},
+			"(completed > ?) OR (completed = ? AND priority < ?) OR (completed = ? AND priority = ? AND position > ?) OR " +
+				"(completed = ? AND priority = ? AND position = ? AND id > ?)",
+			[]interface{}{0, 0, 3, 0, 3, 1.5, 0, 3, 1.5, 7}},
+		{"sort:due,priority-", []interface{}{int64(0), int64(1700000000), 2, 4.0, 9},
+			"((due = 0) > ?) OR ((due = 0) = ? AND due > ?) OR ((due = 0) = ? AND due = ? AND priority < ?) OR " +
+				"((due = 0) = ? AND due = ? AND priority = ? AND position > ?) OR " +
+				"((due = 0) = ? AND due = ? AND priority = ? AND position = ? AND id > ?)",
+			[]interface{}{int64(0), int64(0), int64(1700000000), int64(0), int64(1700000000), 2,
+				int64(0), int64(1700000000), 2, 4.0, int64(0), int64(1700000000), 2, 4.0, 9}},
+		{"sort:name- limit:5", []interface{}{"m", 2.0, 4},
+			"(name < ?) OR (name = ? AND position > ?) OR (name = ? AND position = ? AND id > ?)",
+			[]interface{}{"m", "m", 2.0, "m", 2.0, 4}},
+		// The cursor is combined with the filter's own conditions
+		{"priority >= 2 sort:id", []interface{}{5, 1.0, 5},
+			"((id > ?) OR (id = ? AND position > ?) OR (id = ? AND position = ? AND id > ?)) AND (priority >= ?)",
+			[]interface{}{5, 5, 1.0, 5, 1.0, 5, 2}},
+	}
+	for _, test := range tests {
+		f, err := parseFilter(test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.after(test.cursor)
+		if f.where != test.where || !reflect.DeepEqual(f.args, test.args) {
+			t.Errorf("after(%v) on %q = %q %v, want %q %v", test.cursor, test.expr, f.where, f.args, test.where, test.args)
+		}
+	}
+}
@@ -6,24 +6,124 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func add(d *DbTable, f *flag.FlagSet) todo {
+// addOptions are the flags of todo add, applied to every todo added
+type addOptions struct {
+	content     string
+	priority    int
+	prioritySet bool
+	due         int64
+	raw         bool
+}
+
+// build makes a todo from a name, reading the quick add syntax from it
+// unless -raw was given. Flags take precedence over the name and the active
+// context fills in attributes that were given by neither.
+func (o *addOptions) build(d *DbTable, name string) (todo, quickAdd, error) {
+	t := todo{id: 1, name: name, content: o.content, priority: Priority(o.priority), completed: 0, due: o.due}
+	var q quickAdd
+	prioritySet := o.prioritySet
+	if !o.raw {
+		var err error
+		if q, err = parseQuickAdd(name); err != nil {
+			return t, q, err
+		}
+		t.name, t.tags, t.context = q.name, q.tags, q.context
+		if q.priority != 0 && !prioritySet {
+			t.priority, prioritySet = q.priority, true
+		}
+		if !q.due.IsZero() && o.due == 0 {
+			t.due = q.due.Unix()
+		}
+	}
+	if !prioritySet {
+		t.priority = Priority(d.context.defaultPriority(o.priority))
+	}
+	return d.context.fillDefaults(t), q, nil
+}
+
+func add(d *DbTable, f *flag.FlagSet, config *Config) todo {
+	o := &addOptions{}
 	var name string
-	var content string
-	var priority int
-	f.StringVar(&name, "n", "", "Name of todo")
-	f.StringVar(&content, "c", "", "Content of todo")
-	f.IntVar(&priority, "p", 1, "Priority of todo (1 <low> - 3 <high>)")
+	var due string
+	var file string
+	f.StringVar(&name, "n", "", "Name of todo, can also be given after the flags")
+	f.StringVar(&o.content, "c", "", "Content of todo")
+	f.IntVar(&o.priority, "p", 1, "Priority of todo (1 <low> - 3 <high>)")
+	f.StringVar(&due, "due", "", "Due date of todo (e.g. 2026-11-01, tomorrow 14:00, next fri, +2w)")
+	edit := f.Bool("e", false, "Write the todo in $VISUAL or $EDITOR")
+	f.BoolVar(&o.raw, "raw", false, "Store the name as given, without reading +tag, !priority, due:date or @context from it")
+	f.StringVar(&file, "f", "", "Add one todo per line of a file, or - for stdin")
+	output := outputFlags(f)
 
 	f.Parse(os.Args[2:])
+	format := output.load(config)
 	if len(name) == 0 {
+		name = strings.Join(f.Args(), " ")
+	}
+	if name == "-" && len(file) == 0 {
+		file = "-"
+	}
+	if len(name) == 0 && len(file) == 0 && !*edit {
 		// Must have a name
 		f.PrintDefaults()
 		os.Exit(1)
 	}
 
-	t := todo{id: 1, name: name, content: content, priority: Priority(priority), completed: 0}
+	f.Visit(func(fl *flag.Flag) {
+		if fl.Name == "p" {
+			o.prioritySet = true
+		}
+	})
+	if len(due) > 0 {
+		var err error
+		if o.due, err = parseDue(due); err != nil {
+			fmt.Println("Error reading due date: ", err)
+			os.Exit(1)
+		}
+	}
+
+	if len(file) > 0 {
+		if *edit {
+			fmt.Println("-e cannot be used when adding todos from a file")
+			os.Exit(1)
+		}
+		addBulk(d, o, file, format)
+		return todo{}
+	}
+
+	t := todo{id: 1, name: name, content: o.content, priority: Priority(o.priority), due: o.due}
+	if len(name) > 0 {
+		var q quickAdd
+		var err error
+		if t, q, err = o.build(d, name); err != nil {
+			fmt.Println("Error reading todo name: ", err)
+			os.Exit(1)
+		}
+		if recognised := q.recognised(); recognised != "" && format.isTable() {
+			fmt.Println("Recognised", recognised)
+		}
+	} else {
+		if !o.prioritySet {
+			t.priority = Priority(d.context.defaultPriority(o.priority))
+		}
+		t = d.context.fillDefaults(t)
+	}
+	if *edit {
+		var err error
+		if t, err = editTodo(t); err != nil {
+			if err == errEditAborted {
+				fmt.Println("Aborted, no todo added")
+				os.Exit(0)
+			}
+			fmt.Println("Error editing todo: ", err)
+			os.Exit(1)
+		}
+	}
 
 	id, err := d.insertTodo(t)
 	if err != nil {
@@ -31,42 +131,266 @@ func add(d *DbTable, f *flag.FlagSet) todo {
 		os.Exit(1)
 	}
 	n := d.getTodoById(id)
-	fmt.Println("Inserted todo: ")
-	NewConsolePrint().printTodos([]todo{n})
+	if format.isTable() {
+		fmt.Println("Inserted todo: ")
+	}
+	format.print(d, []todo{n})
 	return t
 }
 
-func list(d *DbTable, f *flag.FlagSet) {
-	var status string
-	var limit int
-	f.StringVar(&status, "s", "incomplete", "Status of todo")
-	f.IntVar(&limit, "l", 10, "Limit number of todos to return")
-	f.Parse(os.Args[2:])
+// addBulk adds a todo for each line of file, - for stdin. Nothing is added
+// if any line cannot be read.
+func addBulk(d *DbTable, o *addOptions, file string, format *todoOutput) {
+	in := os.Stdin
+	if file != "-" {
+		var err error
+		if in, err = os.Open(file); err != nil {
+			fmt.Println("Error opening file: ", err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+	lines, indented, err := readBulk(in)
+	if err != nil {
+		fmt.Println("Error reading todos: ", err)
+		os.Exit(1)
+	}
+	if len(lines) == 0 {
+		fmt.Println("No todos to add")
+		return
+	}
 
 	var todos []todo
-	var countTodos int
+	failed := false
+	for _, line := range lines {
+		t, _, err := o.build(d, line.name)
+		if err != nil {
+			fmt.Printf("Error on line %d: %v\n", line.number, err)
+			failed = true
+			continue
+		}
+		if line.completed {
+			t.completed = 1
+		}
+		todos = append(todos, t)
+	}
+	if failed {
+		fmt.Println("No todos were added")
+		os.Exit(1)
+	}
+
+	ids, err := d.insertTodos(todos)
+	if err != nil {
+		fmt.Println("Error inserting todos: ", err)
+		os.Exit(1)
+	}
+	added := make([]todo, len(ids))
+	for i, id := range ids {
+		added[i] = d.getTodoById(id)
+	}
+	if format.isTable() {
+		if indented {
+			fmt.Println("Todos do not have subtasks, indented lines were added as todos of their own")
+		}
+		fmt.Printf("Inserted %d todos: \n", len(added))
+	}
+	format.print(d, added)
+}
+
+// listOptions are the flags of todo list, also used to check saved views
+type listOptions struct {
+	status   string
+	limit    int
+	sortBy   string
+	priority int
+	page     int
+	pageSize int
+	after    int
+	output   outputOptions
+}
+
+func listFlags(f *flag.FlagSet) *listOptions {
+	o := &listOptions{}
+	f.StringVar(&o.status, "s", "incomplete", "Status of todo (incomplete | complete | all)")
+	f.IntVar(&o.limit, "l", 10, "Limit number of todos to return")
+	f.StringVar(&o.sortBy, "sort", "priority", "Order of todos (priority | urgency)")
+	f.IntVar(&o.priority, "p", 0, "Only todos with this priority (1 <low> - 3 <high>)")
+	f.IntVar(&o.page, "page", 0, "Show this page of the todos, counting from 1")
+	f.IntVar(&o.pageSize, "page-size", 0, "Number of todos on a page (default the limit)")
+	f.IntVar(&o.after, "after", 0, "Show the page of todos after the one with this id, e.g. the last one shown")
+	o.output.register(f)
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: todo list [@view] [flags] [filter expression]")
+		fmt.Fprintln(f.Output(), `  e.g. todo list 'priority >= 2 and (name ~ "deploy" or content ~ "rollback") and not completed sort:due limit:5'`)
+		fmt.Fprintln(f.Output(), "  fields: "+filterFieldNames()+", operators: = != < <= > >= ~ !~, and, or, not")
+		f.PrintDefaults()
+	}
+	return o
+}
+
+// buildFilter combines the parsed flags and filter expression of f with the
+// filter expression of a saved view, if any
+func (o *listOptions) buildFilter(f *flag.FlagSet, viewFilter string) (*todoFilter, error) {
+	filter, err := parseFilter(strings.Join(f.Args(), " "))
+	if err != nil {
+		return nil, err
+	}
+	if viewFilter != "" {
+		saved, err := parseFilter(viewFilter)
+		if err != nil {
+			return nil, err
+		}
+		filter.merge(saved)
+	}
+
+	// A filter expression chooses its own status unless -s is given
+	statusSet := filter.where == ""
+	f.Visit(func(fl *flag.Flag) {
+		if fl.Name == "s" {
+			statusSet = true
+		}
+	})
+	if statusSet {
+		if err := filterStatus(filter, o.status); err != nil {
+			return nil, err
+		}
+	}
+	if o.priority != 0 {
+		filter.and("priority = ?", o.priority)
+	}
+
+	if _, err := parseOutputFormat(o.output.format); err != nil {
+		return nil, err
+	}
+
+	switch o.sortBy {
+	case "priority":
+	case "urgency":
+		filter.sort = []sortKey{{field: sortUrgency}}
+	default:
+		return nil, fmt.Errorf("invalid sort %q, expected priority or urgency", o.sortBy)
+	}
+	return filter, nil
+}
+
+// filterStatus limits filter to the todos with the status given by -s
+func filterStatus(filter *todoFilter, status string) error {
 	switch status {
 	case "incomplete":
-		todos = d.getTodosByStatus(0, limit)
-		countTodos = d.getTodosCountByStatus(0)
+		filter.and("completed = ?", 0)
 	case "complete":
-		todos = d.getTodosByStatus(1, limit)
-		countTodos = d.getTodosCountByStatus(1)
+		filter.and("completed = ?", 1)
 	case "all":
-		todos = d.getAllTodos(limit)
-		countTodos = d.getTodosCount()
 	default:
-		fmt.Println("Invalid status")
+		return fmt.Errorf("invalid status %q, expected incomplete, complete or all", status)
+	}
+	return nil
+}
+
+func list(d *DbTable, f *flag.FlagSet, config *Config) {
+	o := listFlags(f)
+	args, viewFilter, err := expandView(os.Args[2:], config)
+	if err != nil {
+		fmt.Println("Error reading view: ", err)
+		os.Exit(1)
 	}
+	f.Parse(args)
+	format := o.output.load(config)
 
-	NewConsolePrint().printTodos(todos)
-	returnedTodos := len(todos)
-	if returnedTodos < countTodos {
-		fmt.Printf("Showing %d of %d todos", returnedTodos, countTodos)
+	filter, err := o.buildFilter(f, viewFilter)
+	if err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+	limit := o.limit
+	if filter.limit > 0 {
+		limit = filter.limit
+	}
+	pageSize := o.pageSize
+	if pageSize <= 0 {
+		pageSize = limit
+	}
+	if o.page < 0 || o.after < 0 || pageSize <= 0 {
+		fmt.Println("Error: -page, -after and -page-size must be positive")
+		os.Exit(1)
+	}
+	if o.page > 0 && o.after > 0 {
+		fmt.Println("Error: give either -page or -after")
+		os.Exit(1)
+	}
+
+	if err = d.checkFilter(filter); err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+
+	var todos []todo
+	if filter.sortsByUrgency() {
+		scorer, err := newUrgencyScorer(config)
+		if err != nil {
+			fmt.Println("Error reading urgency coefficients: ", err)
+			os.Exit(1)
+		}
+		// Urgency is not known to the database so every match is scored
+		todos = d.queryTodos(&todoFilter{where: filter.where, args: filter.args, sort: filter.sort}, -1)
+		scorer.sortByUrgency(todos)
+		if o.after > 0 {
+			start := -1
+			for i, t := range todos {
+				if t.id == o.after {
+					start = i + 1
+				}
+			}
+			if start == -1 {
+				fmt.Printf("Error: todo %d is not in the list\n", o.after)
+				os.Exit(1)
+			}
+			todos = todos[start:minInt(start+pageSize, len(todos))]
+		} else if o.page > 0 {
+			start := minInt((o.page-1)*pageSize, len(todos))
+			todos = todos[start:minInt(start+pageSize, len(todos))]
+		} else if len(todos) > limit {
+			todos = todos[:limit]
+		}
+	} else if o.after > 0 {
+		filter.limit = 0
+		if todos, err = d.queryAfter(filter, pageSize, o.after); err != nil {
+			fmt.Println("Error: ", err)
+			os.Exit(1)
+		}
+	} else if o.page > 0 {
+		filter.limit = 0
+		todos = d.queryPage(filter, pageSize, o.page)
+	} else {
+		todos = d.queryTodos(filter, limit)
+	}
+	countTodos, err := d.countTodos(filter)
+	if err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
 	}
+
+	withPager(config, func() {
+		format.print(d, todos)
+		if !format.isTable() {
+			return
+		}
+		if o.page > 0 {
+			pages := maxInt((countTodos+pageSize-1)/pageSize, 1)
+			fmt.Printf("Page %d of %d (%d todos)\n", o.page, pages, countTodos)
+		} else if o.after > 0 {
+			if len(todos) == pageSize {
+				fmt.Printf("Showing %d of %d todos, next page with -after %d\n", len(todos), countTodos, todos[len(todos)-1].id)
+			} else {
+				fmt.Printf("Showing the last %d of %d todos\n", len(todos), countTodos)
+			}
+		} else if len(todos) < countTodos {
+			fmt.Printf("Showing %d of %d todos\n", len(todos), countTodos)
+		}
+	})
 }
 
-func delete(d *DbTable, f *flag.FlagSet) {
+func deleteCmd(d *DbTable, f *flag.FlagSet) {
 	var id int
 	f.IntVar(&id, "id", 0, "Id of todo to delete")
 	f.Parse(os.Args[2:])
@@ -76,6 +400,7 @@ func delete(d *DbTable, f *flag.FlagSet) {
 		os.Exit(1)
 	}
 
+	checkInContext(d, id)
 	todo := d.getTodoById(id)
 	id, err := d.deleteTodoById(id)
 	if err != nil {
@@ -85,16 +410,19 @@ func delete(d *DbTable, f *flag.FlagSet) {
 	fmt.Println("Deleted todo: ", todo)
 }
 
-func complete(d *DbTable, f *flag.FlagSet) {
+func complete(d *DbTable, f *flag.FlagSet, config *Config) {
 	var id int
 	f.IntVar(&id, "id", 0, "Id of todo to complete")
+	output := outputFlags(f)
 	f.Parse(os.Args[2:])
+	format := output.load(config)
 
 	if id == 0 {
 		// Must have an id
 		f.PrintDefaults()
 		os.Exit(1)
 	}
+	checkInContext(d, id)
 	todoFetched := d.getTodoById(id)
 	todoFetched.completed = 1
 	err := d.updateTodoById(id, todoFetched)
@@ -103,35 +431,65 @@ func complete(d *DbTable, f *flag.FlagSet) {
 		os.Exit(1)
 	}
 	newTodo := d.getTodoById(id)
-	fmt.Println("Completed todo: ")
-	NewConsolePrint().printTodos([]todo{newTodo})
+	if format.isTable() {
+		fmt.Println("Completed todo: ")
+	}
+	format.print(d, []todo{newTodo})
 }
 
-func view(d *DbTable, f *flag.FlagSet) {
+func view(d *DbTable, f *flag.FlagSet, config *Config) {
 	var id int
 	f.IntVar(&id, "id", 0, "Id of todo to view")
+	output := outputFlags(f)
 	f.Parse(os.Args[2:])
+	format := output.load(config)
 	if id == 0 {
 		// Must have an id
 		f.PrintDefaults()
 		os.Exit(1)
 	}
+	checkInContext(d, id)
 	todoView := d.getTodoById(id)
-	NewConsolePrint().printTodos([]todo{todoView})
+	format.print(d, []todo{todoView})
+	if !format.isTable() {
+		return
+	}
+	if due, hasDue := todoView.dueTime(); hasDue {
+		fmt.Println("Due: ", formatDate(due))
+	}
+	if len(todoView.tags) > 0 {
+		fmt.Println("Tags: ", joinTags(todoView.tags))
+	}
+	if todoView.context != "" {
+		fmt.Println("Context: ", todoView.context)
+	}
+	scorer, err := newUrgencyScorer(config)
+	if err != nil {
+		fmt.Println("Error reading urgency coefficients: ", err)
+		os.Exit(1)
+	}
+	scorer.printBreakdown(todoView)
 }
 
-func readConfig() (*Config, error) {
-	// Read config file from default location ~/.config/todo/config.json
-	// If the config file does not exist then create it with default values
-
+// configFilePath returns the default location of the config file
+func configFilePath() (string, error) {
 	// Get home directory
 	home, err := os.UserHomeDir()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	// join home directory with config file path
-	configPath := filepath.Join(home, ".todo", "config.json")
+	return filepath.Join(home, ".todo", "config.json"), nil
+}
+
+func readConfig() (*Config, error) {
+	// Read config file from default location ~/.todo/config.json
+	// If the config file does not exist then create it with default values
+	configPath, err := configFilePath()
+	if err != nil {
+		return nil, err
+	}
 
 	// Check if config file exists
 	if _, err := os.Stat(configPath); errors.Is(err, os.ErrNotExist) {
@@ -165,16 +523,21 @@ func newDb(d *DbTable, f *flag.FlagSet, config *Config) {
 	}
 }
 
-func update(d *DbTable, f *flag.FlagSet) {
+func update(d *DbTable, f *flag.FlagSet, config *Config) {
 	var id int
 	var name string
 	var content string
 	var priority int
+	var due string
 	f.IntVar(&id, "id", 0, "Id of todo to update")
 	f.StringVar(&name, "n", "", "Name of todo")
 	f.StringVar(&content, "c", "", "Content of todo")
-	f.IntVar(&priority, "p", 1, "Priority of todo (1 <low> - 3 <high>)")
+	f.IntVar(&priority, "p", 0, "Priority of todo (1 <low> - 3 <high>)")
+	f.StringVar(&due, "due", "", "Due date of todo (e.g. 2026-11-01, tomorrow 14:00, next fri, +2w, none to clear)")
+	edit := f.Bool("e", false, "Edit the todo in $VISUAL or $EDITOR")
+	output := outputFlags(f)
 	f.Parse(os.Args[2:])
+	format := output.load(config)
 
 	if id == 0 {
 		// Must have an id
@@ -182,7 +545,9 @@ func update(d *DbTable, f *flag.FlagSet) {
 		os.Exit(1)
 	}
 
+	checkInContext(d, id)
 	todoUpdate := d.getTodoById(id)
+	original := todoUpdate
 	if len(name) > 0 {
 		todoUpdate.name = name
 	}
@@ -192,14 +557,40 @@ func update(d *DbTable, f *flag.FlagSet) {
 	if priority > 0 {
 		todoUpdate.priority = Priority(priority)
 	}
+	if len(due) > 0 {
+		var err error
+		if todoUpdate.due, err = parseDue(due); err != nil {
+			fmt.Println("Error reading due date: ", err)
+			os.Exit(1)
+		}
+	}
+	if *edit {
+		var err error
+		if todoUpdate, err = editTodo(todoUpdate); err != nil {
+			if err == errEditAborted {
+				fmt.Println("Aborted, todo not changed")
+				os.Exit(0)
+			}
+			fmt.Println("Error editing todo: ", err)
+			os.Exit(1)
+		}
+		if format.isTable() {
+			fmt.Println("Changes: ")
+			if !printTodoDiff(original, todoUpdate) {
+				fmt.Println("  none")
+			}
+		}
+	}
 	err := d.updateTodoById(id, todoUpdate)
 	if err != nil {
 		fmt.Println("Error updating todo: ", err)
 		os.Exit(1)
 	}
 	newTodo := d.getTodoById(id)
-	fmt.Println("Updated todo: ")
-	NewConsolePrint().printTodos([]todo{newTodo})
+	if format.isTable() {
+		fmt.Println("Updated todo: ")
+	}
+	format.print(d, []todo{newTodo})
 }
 
 func configCmd(args []string, config *Config) {
@@ -208,6 +599,10 @@ func configCmd(args []string, config *Config) {
 		fmt.Print("Too few arguments\n", configOptions)
 		os.Exit(1)
 	}
+	if len(args) > 1 && strings.HasPrefix(args[1], encryptionConfigPrefix) {
+		fmt.Println("Error: " + args[1] + " is managed by todo encrypt and todo decrypt")
+		os.Exit(1)
+	}
 	switch args[0] {
 	case "view":
 		err := config.View()
@@ -221,6 +616,54 @@ func configCmd(args []string, config *Config) {
 			fmt.Println("Usage: todo config set <key> <value>")
 			os.Exit(1)
 		}
+		if strings.HasPrefix(args[1], columnConfigPrefix) {
+			if err := validColumnKey(args[1], args[2]); err != nil {
+				fmt.Println("Error setting config: ", err)
+				os.Exit(1)
+			}
+		}
+		if n, err := strconv.Atoi(args[2]); args[1] == configWidth && (err != nil || n < 0) {
+			fmt.Println("Error setting config: width must be a whole number, 0 for no limit")
+			os.Exit(1)
+		}
+		if args[1] == configLayout && !isLayoutMode(args[2]) {
+			fmt.Println("Error setting config: expected " + strings.Join(layoutModes, ", "))
+			os.Exit(1)
+		}
+		if args[1] == configColor && !isColorMode(args[2]) {
+			fmt.Println("Error setting config: expected " + strings.Join(colorModes, ", "))
+			os.Exit(1)
+		}
+		if args[1] == configTheme {
+			if _, err := loadTheme(args[2], config); err != nil {
+				fmt.Println("Error setting config: ", err)
+				os.Exit(1)
+			}
+		}
+		if args[1] == configWeekStart {
+			if _, err := parseWeekday(args[2]); err != nil {
+				fmt.Println("Error setting config: ", err)
+				os.Exit(1)
+			}
+		}
+		if args[1] == configTimeZone {
+			if _, err := time.LoadLocation(args[2]); err != nil {
+				fmt.Println("Error setting config: ", err)
+				os.Exit(1)
+			}
+		}
+		if args[1] == configColumns {
+			if _, err := parseColumns(args[2]); err != nil {
+				fmt.Println("Error setting config: ", err)
+				os.Exit(1)
+			}
+		}
+		if strings.HasPrefix(args[1], urgencyConfigPrefix) {
+			if err := validUrgencyKey(args[1], args[2]); err != nil {
+				fmt.Println("Error setting config: ", err)
+				os.Exit(1)
+			}
+		}
 		err := config.Set(args[1], args[2])
 		if err != nil {
 			fmt.Println("Error setting config: <", args[1], "> with value <", args[2], ">: ", err)
@@ -236,3 +679,59 @@ func configCmd(args []string, config *Config) {
 		fmt.Println(configOptions)
 	}
 }
+
+func move(d *DbTable, f *flag.FlagSet) {
+	var id int
+	var before int
+	var after int
+	var top bool
+	var bottom bool
+	f.IntVar(&id, "id", 0, "Id of todo to move")
+	f.IntVar(&before, "before", 0, "Id of todo to move above")
+	f.IntVar(&after, "after", 0, "Id of todo to move below")
+	f.BoolVar(&top, "top", false, "Move to the top of the list")
+	f.BoolVar(&bottom, "bottom", false, "Move to the bottom of the list")
+	f.Parse(os.Args[2:])
+
+	options := 0
+	for _, set := range []bool{before != 0, after != 0, top, bottom} {
+		if set {
+			options++
+		}
+	}
+	if id == 0 || options != 1 {
+		// Must have an id and exactly one destination
+		fmt.Println("Usage: todo move -id <id> (--before <id> | --after <id> | --top | --bottom)")
+		f.PrintDefaults()
+		os.Exit(1)
+	}
+
+	where, anchor := moveBefore, before
+	switch {
+	case after != 0:
+		where, anchor = moveAfter, after
+	case top:
+		where = moveTop
+	case bottom:
+		where = moveBottom
+	}
+	if anchor == id {
+		fmt.Println("Cannot move a todo relative to itself")
+		os.Exit(1)
+	}
+
+	checkInContext(d, id, anchor)
+	err := d.moveTodo(id, where, anchor)
+	if err != nil {
+		fmt.Println("Error moving todo: ", err)
+		os.Exit(1)
+	}
+	if anchor != 0 {
+		moved, other := d.getTodoById(id), d.getTodoById(anchor)
+		if moved.priority != other.priority {
+			fmt.Printf("Note: todo %d has priority %d and todo %d has priority %d, lists are ordered by priority first\n",
+				id, moved.priority, anchor, other.priority)
+		}
+	}
+	fmt.Println("Moved todo: ", id)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// todo forecast estimates when the open todos matching a filter will all be
+// done. Each run of the simulation repeatedly picks one of the past weeks at
+// random and replays how many todos were added and completed in it until
+// none are left, so the spread of the results reflects how much throughput
+// has varied.
+
+// forecastConfidences are the chances of being done by the date reported
+var forecastConfidences = []float64{0.5, 0.85, 0.95}
+
+// forecastLimit is the number of weeks after which a run gives up, when
+// todos are added as fast as they are completed
+const forecastLimit = 520
+
+// forecastWeek is what happened in one week of the history
+type forecastWeek struct {
+	added     int
+	completed int
+}
+
+func forecastCmd(d *DbTable, f *flag.FlagSet) {
+	weeks := f.Int("weeks", 12, "Number of past weeks to sample throughput from")
+	runs := f.Int("runs", 10000, "Number of simulations")
+	fixed := f.Bool("fixed", false, "Assume no more todos are added that match the filter")
+	all := f.Bool("all", false, "Sample completions from every todo rather than only those matching the filter")
+	seed := f.Int64("seed", 0, "Seed for the simulation, to repeat a forecast (default random)")
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: todo forecast [flags] [filter expression]")
+		fmt.Fprintln(f.Output(), `  e.g. todo forecast 'tags = release'`)
+		f.PrintDefaults()
+	}
+	f.Parse(os.Args[2:])
+	if *weeks < 1 || *runs < 1 {
+		fmt.Println("Error: -weeks and -runs must be at least 1")
+		os.Exit(1)
+	}
+	filter, err := parseFilter(strings.Join(f.Args(), " "))
+	if err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+	if err = d.checkFilter(filter); err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+
+	open := *filter
+	open.and("completed = ?", 0)
+	backlog, err := d.countTodos(&open)
+	if err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+
+	// -all widens the completions sampled, todos added still have to match
+	// the filter to grow the backlog
+	completions := filter
+	if *all {
+		completions = &todoFilter{}
+	}
+	now := time.Now()
+	history := forecastHistory(d, filter, completions, now, *weeks)
+
+	c := newConsolePrint(d)
+	c.printContextHeader()
+	added, completed := 0, 0
+	for _, w := range history {
+		added += w.added
+		completed += w.completed
+	}
+	fmt.Printf("%v open. Over the last %v %.1f a week were completed and %.1f added.\n",
+		plural(backlog, "todo"), plural(*weeks, "week"), float64(completed)/float64(*weeks), float64(added)/float64(*weeks))
+	if backlog == 0 {
+		fmt.Println("Nothing left to do.")
+		return
+	}
+	if completed == 0 {
+		fmt.Println("No todos were completed in that time so there is nothing to forecast from, try -weeks or -all.")
+		return
+	}
+	if *fixed {
+		for i := range history {
+			history[i].added = 0
+		}
+	}
+
+	if *seed == 0 {
+		*seed = now.UnixNano()
+	}
+	results := simulateForecast(rand.New(rand.NewSource(*seed)), history, backlog, *runs)
+	fmt.Println()
+	fmt.Println(c.color["header"] + "Chance  Done by" + c.color["reset"])
+	for _, confidence := range forecastConfidences {
+		needed := results[int(math.Ceil(confidence*float64(len(results))))-1]
+		if needed > forecastLimit {
+			fmt.Printf("%5.0f%%  not within %v years, todos are added as fast as they are done (see -fixed)\n", confidence*100, forecastLimit/52)
+			continue
+		}
+		done := startOfDay(now).AddDate(0, 0, 7*needed)
+		fmt.Printf("%5.0f%%  %v (%v)\n", confidence*100, done.Format("Mon 2 Jan 2006"), plural(needed, "week"))
+	}
+}
+
+// forecastHistory counts the todos matching added that were added and those
+// matching completed that were completed in each of the weeks before now,
+// the most recent first
+func forecastHistory(d *DbTable, added *todoFilter, completed *todoFilter, now time.Time, weeks int) []forecastWeek {
+	history := make([]forecastWeek, weeks)
+	start := now.AddDate(0, 0, -7*weeks)
+	week := func(t int64) int {
+		return int(now.Sub(time.Unix(t, 0)).Hours() / (24 * 7))
+	}
+
+	for w, count := range d.getAddedPerWeek(added, now, weeks) {
+		history[w].added = count
+	}
+	for _, t := range d.getCompletions(completed, start, now) {
+		if w := week(t.completedAt); w >= 0 && w < weeks {
+			history[w].completed++
+		}
+	}
+	return history
+}
+
+// simulateForecast returns the number of weeks each run took to finish
+// backlog todos, sorted. Runs that never finish take forecastLimit+1.
+func simulateForecast(r *rand.Rand, history []forecastWeek, backlog int, runs int) []int {
+	results := make([]int, runs)
+	for run := range results {
+		remaining := backlog
+		weeks := 0
+		for remaining > 0 && weeks <= forecastLimit {
+			w := history[r.Intn(len(history))]
+			remaining += w.added - w.completed
+			weeks++
+		}
+		results[run] = weeks
+	}
+	sort.Ints(results)
+	return results
+}
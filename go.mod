@@ -5,9 +5,10 @@ go 1.19
 require (
 	github.com/kopoli/go-terminal-size v0.0.0-20170219200355-5c97524c8b54
 	github.com/mattn/go-sqlite3 v1.14.15
+	github.com/rivo/uniseg v0.4.4
+	golang.org/x/crypto v0.6.0
+	golang.org/x/sys v0.5.0
+	golang.org/x/term v0.5.0
 )
 
-require (
-	github.com/mattn/go-isatty v0.0.16 // indirect
-	golang.org/x/sys v0.0.0-20220919091848-fb04ddd9f9c8 // indirect
-)
+require github.com/mattn/go-isatty v0.0.16 // indirect
@@ -0,0 +1,18 @@
+//go:build cgo
+
+package main
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// isLocked reports whether err is a transient lock held by another process
+func isLocked(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
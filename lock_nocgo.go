@@ -0,0 +1,10 @@
+//go:build !cgo
+
+package main
+
+// isLocked reports whether err is a transient lock held by another process.
+// Without cgo the sqlite3 driver is only a stub that fails to open any
+// database, so there is never a lock to wait for.
+func isLocked(err error) bool {
+	return false
+}
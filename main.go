@@ -9,13 +9,46 @@ import (
 )
 
 func main() {
+	// --color before the subcommand applies to every command, doctor included
+	args, colorMode, err := extractColorFlag(os.Args)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(1)
+	}
+	os.Args = args
+
+	// doctor diagnoses a broken config or database so must run before
+	// either is loaded, colour uses the default theme
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		defaults := NewConfig()
+		setupColor(colorMode, defaults)
+		doctorFlags := flag.NewFlagSet("doctor", flag.ExitOnError)
+		registerColorFlag(doctorFlags, defaults)
+		doctor(doctorFlags)
+		return
+	}
+
 	// Read internal config
 	config, err := readConfig()
 	if err != nil {
-		panic(err)
+		fmt.Println("Error reading config: ", err)
+		fmt.Println("Run 'todo doctor' to diagnose the problem.")
+		os.Exit(1)
 	}
 
+	setupColor(colorMode, config)
+	setupWidth(config)
+	setupDates(config)
+
 	d := &DbTable{dbName: config.GetDbName(), tableName: config.GetTableName()}
+	if len(os.Args) > 1 && os.Args[1] != "context" {
+		d.context, err = loadContext(config)
+		if err != nil {
+			fmt.Println("Error loading context: ", err)
+			fmt.Println("Run 'todo context none' to clear it.")
+			os.Exit(1)
+		}
+	}
 
 	newCmd := flag.NewFlagSet("init", flag.ExitOnError)
 	addCmd := flag.NewFlagSet("add", flag.ExitOnError)
@@ -23,8 +56,29 @@ func main() {
 	delCmd := flag.NewFlagSet("del", flag.ExitOnError)
 	compCmd := flag.NewFlagSet("comp", flag.ExitOnError)
 	updateCmd := flag.NewFlagSet("update", flag.ExitOnError)
+	moveCmd := flag.NewFlagSet("move", flag.ExitOnError)
+	viewsCmd := flag.NewFlagSet("views", flag.ExitOnError)
+	contextFlags := flag.NewFlagSet("context", flag.ExitOnError)
+	dateFlags := flag.NewFlagSet("date", flag.ExitOnError)
+	themeFlags := flag.NewFlagSet("theme", flag.ExitOnError)
+	tuiFlags := flag.NewFlagSet("tui", flag.ExitOnError)
+	agendaFlags := flag.NewFlagSet("agenda", flag.ExitOnError)
+	calFlags := flag.NewFlagSet("cal", flag.ExitOnError)
+	statsFlags := flag.NewFlagSet("stats", flag.ExitOnError)
+	forecastFlags := flag.NewFlagSet("forecast", flag.ExitOnError)
+	chartFlags := flag.NewFlagSet("chart", flag.ExitOnError)
+	configFlags := flag.NewFlagSet("config", flag.ExitOnError)
+	encryptFlags := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	decryptFlags := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	unlockFlags := flag.NewFlagSet("unlock", flag.ExitOnError)
+
+	for _, f := range []*flag.FlagSet{newCmd, addCmd, listCmd, delCmd, compCmd, updateCmd, moveCmd, viewsCmd, contextFlags,
+		dateFlags, themeFlags, tuiFlags, agendaFlags, calFlags, statsFlags, forecastFlags, chartFlags, configFlags,
+		encryptFlags, decryptFlags, unlockFlags} {
+		registerColorFlag(f, config)
+	}
 
-	expectedInput := "Expected 'init', 'add', 'del', 'comp', 'view', 'update', 'list', 'help', or 'config' subcommands"
+	expectedInput := "Expected 'init', 'add', 'del', 'comp', 'view', 'update', 'move', 'list', 'tui', 'agenda', 'cal', 'stats', 'forecast', 'chart', 'views', 'context', 'date', 'theme', 'doctor', 'encrypt', 'decrypt', 'unlock', 'help', or 'config' subcommands"
 
 	inputHelp :=
 		`Usage of todo:
@@ -40,10 +94,44 @@ func main() {
 	  View an individual todo item
   todo update
 	  Update a todo item
+  todo move
+	  Change the order of a todo item within its priority
   todo list
 	  List multiple todo items
+  todo tui
+	  Browse and edit todos full screen, press ? for the keys
+  todo agenda
+	  Show what is due over the next 7 days, overdue todos first
+  todo cal
+	  Show a month with the number of todos due each day
+  todo stats
+	  Show totals, completions over time, streaks and time to complete
+  todo forecast
+	  Estimate when the open todos will be done from past throughput
+  todo chart
+	  Draw a burndown or cumulative flow chart as an SVG file
+  todo views
+	  Save, list and delete named list views
+  todo context
+	  Scope every command to a filter, 'todo context none' to clear
   todo config
 	  View or update config values - Not yet implemented
+  todo date
+	  Preview how a date expression such as 'next fri' resolves
+  todo theme
+	  List themes or preview one, choose with 'todo config set theme <name>'
+  todo doctor
+	  Check the config and database for problems, --fix to repair them
+  todo encrypt
+	  Encrypt todo names and content with a passphrase
+  todo decrypt
+	  Remove encryption from the database
+  todo unlock
+	  Print the derived key to cache with eval "$(todo unlock)"
+
+Commands take --color auto|always|never, before the command or among its
+flags. auto colours output only on a terminal. NO_COLOR turns colour off
+unless --color is given.
 `
 
 	if len(os.Args) < 2 {
@@ -51,23 +139,58 @@ func main() {
 		return
 	}
 
+	// Commands that read or write todos need the key for an encrypted database
+	switch os.Args[1] {
+	case "add", "list", "tui", "agenda", "cal", "stats", "forecast", "chart", "del", "comp", "view", "update", "move":
+		unlock(d, config)
+	}
+
 	switch os.Args[1] {
 	case "init":
 		newDb(d, newCmd, config)
 	case "add":
-		add(d, addCmd)
+		add(d, addCmd, config)
 	case "list":
-		list(d, listCmd)
+		list(d, listCmd, config)
+	case "tui":
+		tuiCmd(d, tuiFlags, config)
+	case "agenda":
+		agendaCmd(d, agendaFlags)
+	case "cal":
+		calCmd(d, calFlags)
+	case "stats":
+		statsCmd(d, statsFlags)
+	case "forecast":
+		forecastCmd(d, forecastFlags)
+	case "chart":
+		chartCmd(d, chartFlags, config)
 	case "del":
-		delete(d, delCmd)
+		deleteCmd(d, delCmd)
 	case "comp":
-		complete(d, compCmd)
+		complete(d, compCmd, config)
 	case "view":
-		view(d, compCmd)
+		view(d, compCmd, config)
 	case "update":
-		update(d, updateCmd)
+		update(d, updateCmd, config)
+	case "move":
+		move(d, moveCmd)
+	case "views":
+		views(viewsCmd, config)
+	case "context":
+		contextCmd(contextFlags, config)
+	case "date":
+		dateCmd(dateFlags)
+	case "theme":
+		themeCmd(themeFlags, config)
 	case "config":
-		configCmd(os.Args[2:], config)
+		configFlags.Parse(os.Args[2:])
+		configCmd(configFlags.Args(), config)
+	case "encrypt":
+		encryptCmd(d, encryptFlags, config)
+	case "decrypt":
+		decryptCmd(d, decryptFlags, config)
+	case "unlock":
+		unlockCmd(unlockFlags, config)
 	case "help":
 		fallthrough
 	case "-h":
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// outputFormats are the values of -o. Every format other than table is
+// plain text without colour, meant to be read by scripts.
+var outputFormats = []string{"table", "json", "jsonl", "csv", "tsv", "yaml", "markdown"}
+
+type outputFormat string
+
+// outputOptions are the -o and -format flags
+type outputOptions struct {
+	format   string
+	template string
+	columns  string
+	layout   string
+}
+
+// outputFlags adds -o and -format to f
+func outputFlags(f *flag.FlagSet) *outputOptions {
+	o := &outputOptions{}
+	o.register(f)
+	return o
+}
+
+func (o *outputOptions) register(f *flag.FlagSet) {
+	f.StringVar(&o.format, "o", "table", "Output format ("+strings.Join(outputFormats, " | ")+")")
+	f.StringVar(&o.template, "format", "", "Go template for each todo, or the name of one in the templates directory of the config")
+	f.StringVar(&o.columns, "columns", "", "Comma separated columns of the table ("+columnNames()+")")
+	f.StringVar(&o.layout, "layout", "", "Layout of the table ("+strings.Join(layoutModes, " | ")+"), auto chooses from the terminal width")
+}
+
+// todoOutput writes todos as chosen by outputOptions
+type todoOutput struct {
+	format   outputFormat
+	template *template.Template
+	scorer   *urgencyScorer
+	columns  []tableColumn
+	layout   string
+}
+
+// load checks the flags, exiting if they are invalid
+func (o *outputOptions) load(config *Config) *todoOutput {
+	out := &todoOutput{format: readOutputFormat(o.format)}
+	var err error
+	if out.columns, err = loadColumns(o.columns, config); err != nil {
+		fmt.Println("Error reading columns: ", err)
+		os.Exit(1)
+	}
+	if out.layout = o.layout; out.layout == "" {
+		out.layout = config.GetValue(configLayout)
+	}
+	if out.layout != "" && !isLayoutMode(out.layout) {
+		fmt.Printf("Error: invalid layout %q, expected %v\n", out.layout, strings.Join(layoutModes, ", "))
+		os.Exit(1)
+	}
+	if o.template == "" {
+		return out
+	}
+	if out.template, err = loadTemplate(o.template, config); err != nil {
+		fmt.Println("Error reading template: ", err)
+		os.Exit(1)
+	}
+	if out.scorer, err = newUrgencyScorer(config); err != nil {
+		fmt.Println("Error reading urgency coefficients: ", err)
+		os.Exit(1)
+	}
+	return out
+}
+
+// isTable reports whether output is for people, in which case commands
+// also print messages around the todos
+func (out *todoOutput) isTable() bool {
+	return out.template == nil && out.format.isTable()
+}
+
+func (out *todoOutput) print(d *DbTable, todos []todo) {
+	if out.isTable() {
+		c := newConsolePrint(d)
+		c.columns = out.columns
+		if out.layout != "" && out.layout != "auto" {
+			c.mode = out.layout
+		}
+		c.printTodos(todos)
+		return
+	}
+	if out.template == nil {
+		printOutput(d, out.format, todos)
+		return
+	}
+	if err := executeTemplate(out.template, out.scorer, todos); err != nil {
+		fmt.Println("Error in template: ", err)
+		os.Exit(1)
+	}
+}
+
+func parseOutputFormat(value string) (outputFormat, error) {
+	for _, format := range outputFormats {
+		if value == format {
+			return outputFormat(value), nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %q, expected one of %v", value, strings.Join(outputFormats, ", "))
+}
+
+// readOutputFormat reads the -o flag, exiting if it is not a known format
+func readOutputFormat(value string) outputFormat {
+	format, err := parseOutputFormat(value)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(1)
+	}
+	return format
+}
+
+func (o outputFormat) isTable() bool {
+	return o == "table"
+}
+
+// todoRecord is a todo as written by the machine readable formats. The field
+// names and their order are part of the output format, add new fields at the
+// end and do not rename them. Times are RFC 3339 in the local time zone and
+// null when not set.
+type todoRecord struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Content   string   `json:"content"`
+	Priority  int      `json:"priority"`
+	Completed bool     `json:"completed"`
+	Created   *string  `json:"created"`
+	Due       *string  `json:"due"`
+	Tags      []string `json:"tags"`
+	Context   string   `json:"context"`
+	Version   int      `json:"version"`
+	// CompletedAt is null for incomplete todos and those completed before
+	// it was recorded
+	CompletedAt *string `json:"completed_at"`
+}
+
+var recordFields = []string{"id", "name", "content", "priority", "completed", "created", "due", "tags", "context", "version", "completed_at"}
+
+func isoTime(seconds int64) *string {
+	if seconds == 0 {
+		return nil
+	}
+	s := time.Unix(seconds, 0).Format(time.RFC3339)
+	return &s
+}
+
+func newTodoRecord(t todo) todoRecord {
+	tags := t.tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return todoRecord{
+		ID:          t.id,
+		Name:        t.name,
+		Content:     t.content,
+		Priority:    int(t.priority),
+		Completed:   t.completed == 1,
+		Created:     isoTime(t.created),
+		Due:         isoTime(t.due),
+		Tags:        tags,
+		Context:     t.context,
+		Version:     t.version,
+		CompletedAt: isoTime(t.completedAt),
+	}
+}
+
+// values returns the fields of r as text in the order of recordFields
+func (r todoRecord) values() []string {
+	optional := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return []string{
+		strconv.Itoa(r.ID),
+		r.Name,
+		r.Content,
+		strconv.Itoa(r.Priority),
+		strconv.FormatBool(r.Completed),
+		optional(r.Created),
+		optional(r.Due),
+		strings.Join(r.Tags, " "),
+		r.Context,
+		strconv.Itoa(r.Version),
+		optional(r.CompletedAt),
+	}
+}
+
+// printOutput writes todos to stdout in format. The table is drawn by
+// ConsolePrint, the other formats always write every field.
+func printOutput(d *DbTable, format outputFormat, todos []todo) {
+	if format.isTable() {
+		newConsolePrint(d).printTodos(todos)
+		return
+	}
+	if err := writeTodos(os.Stdout, format, todos); err != nil {
+		fmt.Println("Error writing todos: ", err)
+		os.Exit(1)
+	}
+}
+
+func writeTodos(w io.Writer, format outputFormat, todos []todo) error {
+	records := make([]todoRecord, len(todos))
+	for i, t := range todos {
+		records[i] = newTodoRecord(t)
+	}
+	switch format {
+	case "json":
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(records)
+	case "jsonl":
+		e := json.NewEncoder(w)
+		for _, r := range records {
+			if err := e.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(recordFields)
+		for _, r := range records {
+			cw.Write(r.values())
+		}
+		cw.Flush()
+		return cw.Error()
+	case "tsv":
+		return writeTSV(w, records)
+	case "yaml":
+		return writeYAML(w, records)
+	case "markdown":
+		return writeMarkdown(w, records)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// writeTSV escapes tabs, newlines and backslashes so that every todo is one
+// line
+func writeTSV(w io.Writer, records []todoRecord) error {
+	escape := strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+	if _, err := fmt.Fprintln(w, strings.Join(recordFields, "\t")); err != nil {
+		return err
+	}
+	for _, r := range records {
+		values := r.values()
+		for i, v := range values {
+			values[i] = escape.Replace(v)
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(values, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeYAML writes a list of mappings. Strings are double quoted using JSON
+// escaping, which is also valid YAML.
+func writeYAML(w io.Writer, records []todoRecord) error {
+	if len(records) == 0 {
+		_, err := fmt.Fprintln(w, "[]")
+		return err
+	}
+	quote := func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b)
+	}
+	optional := func(s *string) string {
+		if s == nil {
+			return "null"
+		}
+		return quote(*s)
+	}
+	for _, r := range records {
+		tags := make([]string, len(r.Tags))
+		for i, tag := range r.Tags {
+			tags[i] = quote(tag)
+		}
+		_, err := fmt.Fprintf(w, "- id: %d\n  name: %v\n  content: %v\n  priority: %d\n  completed: %v\n  created: %v\n  due: %v\n  tags: [%v]\n  context: %v\n  version: %d\n  completed_at: %v\n",
+			r.ID, quote(r.Name), quote(r.Content), r.Priority, r.Completed, optional(r.Created), optional(r.Due), strings.Join(tags, ", "), quote(r.Context), r.Version, optional(r.CompletedAt))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMarkdown writes a GitHub flavoured markdown table
+func writeMarkdown(w io.Writer, records []todoRecord) error {
+	escape := strings.NewReplacer(`|`, `\|`, "\r\n", "<br>", "\n", "<br>")
+	if _, err := fmt.Fprintf(w, "| %v |\n|%v\n", strings.Join(recordFields, " | "), strings.Repeat(" --- |", len(recordFields))); err != nil {
+		return err
+	}
+	for _, r := range records {
+		values := r.values()
+		for i, v := range values {
+			values[i] = escape.Replace(v)
+		}
+		if _, err := fmt.Fprintf(w, "| %v |\n", strings.Join(values, " | ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
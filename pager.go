@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// The pager is the pager config key, then $PAGER, then less -R which keeps
+// colours. off prints straight to the terminal.
+const (
+	configPager  = "pager"
+	defaultPager = "less -R"
+)
+
+func pagerCommand(config *Config) []string {
+	pager := config.GetValue(configPager)
+	if pager == "" {
+		pager = os.Getenv("PAGER")
+	}
+	if pager == "" {
+		pager = defaultPager
+	}
+	if pager == "off" {
+		return nil
+	}
+	return strings.Fields(pager)
+}
+
+// withPager runs print and, when its output is taller than the terminal,
+// shows it through the pager rather than letting it scroll away
+func withPager(config *Config, print func()) {
+	_, height, interactive := consoleSize()
+	pager := pagerCommand(config)
+	if !interactive || height == 0 || len(pager) == 0 {
+		print()
+		return
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		print()
+		return
+	}
+	stdout := os.Stdout
+	var output bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(&output, r)
+		close(copied)
+	}()
+	os.Stdout = w
+	print()
+	os.Stdout = stdout
+	w.Close()
+	<-copied
+	r.Close()
+
+	if bytes.Count(output.Bytes(), []byte("\n")) < height {
+		stdout.Write(output.Bytes())
+		return
+	}
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = &output, stdout, os.Stderr
+	if err = cmd.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error running pager: ", err)
+		if output.Len() > 0 {
+			stdout.Write(output.Bytes())
+		}
+	}
+}
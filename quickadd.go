@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Words of a todo name can set its attributes as it is added, e.g.
+//
+//	todo add "Fix login bug +backend !3 due:fri @office"
+//
+// adds "Fix login bug" tagged backend, with priority 3, due on Friday in the
+// office context. A backslash keeps a word as it is (\+1 is stored as +1) and
+// add -raw turns the syntax off.
+var (
+	tagPattern      = regexp.MustCompile(`^\+([\pL][\pL\pN_./-]*)$`)
+	priorityPattern = regexp.MustCompile(`^!(\d+)$`)
+	contextPattern  = regexp.MustCompile(`^@([\pL][\pL\pN_./-]*)$`)
+)
+
+const duePrefix = "due:"
+
+// quickAdd holds what was recognised in a todo name
+type quickAdd struct {
+	name     string
+	tags     []string
+	priority Priority // 0 when not given
+	due      time.Time
+	context  string
+}
+
+// parseQuickAdd strips the attribute words from name. Due dates use _ for
+// spaces, e.g. due:next_fri or due:tomorrow_14:00.
+func parseQuickAdd(name string) (quickAdd, error) {
+	var q quickAdd
+	var words []string
+	for _, word := range strings.Fields(name) {
+		if strings.HasPrefix(word, `\`) {
+			words = append(words, word[1:])
+			continue
+		}
+		if m := tagPattern.FindStringSubmatch(word); m != nil {
+			q.tags = append(q.tags, m[1])
+			continue
+		}
+		if m := priorityPattern.FindStringSubmatch(word); m != nil {
+			p, err := strconv.Atoi(m[1])
+			if err != nil || p < 1 || p > 3 {
+				return q, fmt.Errorf("%v: priority must be !1, !2 or !3", word)
+			}
+			if q.priority != 0 {
+				return q, fmt.Errorf("%v: priority is given more than once", word)
+			}
+			q.priority = Priority(p)
+			continue
+		}
+		if m := contextPattern.FindStringSubmatch(word); m != nil {
+			if q.context != "" {
+				return q, fmt.Errorf("%v: context is given more than once", word)
+			}
+			q.context = m[1]
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(word), duePrefix) && len(word) > len(duePrefix) {
+			if !q.due.IsZero() {
+				return q, fmt.Errorf("%v: due date is given more than once", word)
+			}
+			due, err := parseDate(strings.ReplaceAll(word[len(duePrefix):], "_", " "))
+			if err != nil {
+				return q, fmt.Errorf("%v: %v", word, err)
+			}
+			q.due = due
+			continue
+		}
+		words = append(words, word)
+	}
+	q.name = strings.Join(words, " ")
+	if q.name == "" {
+		return q, fmt.Errorf("name is empty once tags, priority, due date and context are removed, use -raw to keep them")
+	}
+	return q, nil
+}
+
+// recognised describes the attributes found, or "" when there were none
+func (q quickAdd) recognised() string {
+	var fields []string
+	if len(q.tags) > 0 {
+		fields = append(fields, "tags: "+strings.Join(q.tags, ", "))
+	}
+	if q.priority != 0 {
+		fields = append(fields, fmt.Sprintf("priority: %d", q.priority))
+	}
+	if !q.due.IsZero() {
+		fields = append(fields, fmt.Sprintf("due: %v (%v)", formatDate(q.due), q.due.Format("Monday")))
+	}
+	if q.context != "" {
+		fields = append(fields, "context: "+q.context)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// parseTagList reads tags separated by spaces or commas, each with or
+// without the leading +, as the editor, the TUI and context defaults take them
+func parseTagList(value string) ([]string, error) {
+	var tags []string
+	for _, tag := range strings.Fields(strings.ReplaceAll(value, ",", " ")) {
+		tag = strings.TrimPrefix(tag, "+")
+		if !tagPattern.MatchString("+" + tag) {
+			return nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// parseContextName reads a context with or without the leading @, "" for none
+func parseContextName(value string) (string, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "@")
+	if value != "" && !contextPattern.MatchString("@"+value) {
+		return "", fmt.Errorf("invalid context %q", value)
+	}
+	return value, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQuickAdd(t *testing.T) {
+	nov1 := time.Date(2026, time.November, 1, 0, 0, 0, 0, time.Local)
+	afternoon := time.Date(2026, time.November, 1, 14, 0, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		want quickAdd
+	}{
+		{"Fix login bug +backend !3 due:2026-11-01 @office",
+			quickAdd{name: "Fix login bug", tags: []string{"backend"}, priority: 3, due: nov1, context: "office"}},
+		{"+backend !3 Fix @office login due:2026-11-01 bug",
+			quickAdd{name: "Fix login bug", tags: []string{"backend"}, priority: 3, due: nov1, context: "office"}},
+		{"  plain   name  ", quickAdd{name: "plain name"}},
+		{"Email +a +b.c +a", quickAdd{name: "Email", tags: []string{"a", "b.c", "a"}}},
+		{"Deploy +v1.2/api-x_y", quickAdd{name: "Deploy", tags: []string{"v1.2/api-x_y"}}},
+		{"Buy +café @büro", quickAdd{name: "Buy", tags: []string{"café"}, context: "büro"}},
+		{"Call mum !2 at 5", quickAdd{name: "Call mum at 5", priority: 2}},
+		{"meet due:2026-11-01_14:00", quickAdd{name: "meet", due: afternoon}},
+		{"meet DUE:2026-11-01T14:00", quickAdd{name: "meet", due: afternoon}},
+
+		// Words that only look like attributes are kept
+		{"Support C++ +1 and +", quickAdd{name: "Support C++ +1 and +"}},
+		{"email me@example.com word+tag", quickAdd{name: "email me@example.com word+tag"}},
+		{"really !! now", quickAdd{name: "really !! now"}},
+		{"set due: later", quickAdd{name: "set due: later"}},
+		{`Pay \+1 bill \@home \!2 \due:fri`, quickAdd{name: "Pay +1 bill @home !2 due:fri"}},
+	}
+	for _, test := range tests {
+		got, err := parseQuickAdd(test.name)
+		if err != nil {
+			t.Errorf("parseQuickAdd(%q) error: %v", test.name, err)
+			continue
+		}
+		if got.name != test.want.name || !reflect.DeepEqual(got.tags, test.want.tags) || got.priority != test.want.priority ||
+			!got.due.Equal(test.want.due) || got.context != test.want.context {
+			t.Errorf("parseQuickAdd(%q) = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseQuickAddErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+	}{
+		{"a !0", "!0: priority must be !1, !2 or !3"},
+		{"a !4", "!4: priority must be !1, !2 or !3"},
+		{"a !1 !2", "!2: priority is given more than once"},
+		{"a @x @y", "@y: context is given more than once"},
+		{"a due:2026-11-01 due:2026-11-02", "due:2026-11-02: due date is given more than once"},
+		{"a due:someday", `due:someday: unrecognised date "someday"`},
+		{"a due:fri_25:00", `due:fri_25:00: invalid time "25:00"`},
+		{"+x !2 @y", "name is empty once tags, priority, due date and context are removed"},
+	}
+	for _, test := range tests {
+		_, err := parseQuickAdd(test.name)
+		if err == nil || !strings.HasPrefix(err.Error(), test.msg) {
+			t.Errorf("parseQuickAdd(%q) error = %v, want %q", test.name, err, test.msg)
+		}
+	}
+}
+
+func TestQuickAddRecognised(t *testing.T) {
+	q, err := parseQuickAdd("Fix +backend +auth !3 due:2026-11-01 @office")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "tags: backend, auth, priority: 3, due: 2026-11-01 (Sunday), context: office"
+	if got := q.recognised(); got != want {
+		t.Errorf("recognised() = %q, want %q", got, want)
+	}
+	if q, _ = parseQuickAdd("plain"); q.recognised() != "" {
+		t.Errorf("recognised() = %q for a plain name", q.recognised())
+	}
+}
+
+func TestParseTagList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+		err   string
+	}{
+		{"", nil, ""},
+		{"work home", []string{"work", "home"}, ""},
+		{"+work, +v1.2/api  café", []string{"work", "v1.2/api", "café"}, ""},
+		{"work 1st", nil, `invalid tag "1st"`},
+		{"a+b", nil, `invalid tag "a+b"`},
+		{"@home", nil, `invalid tag "@home"`},
+	}
+	for _, test := range tests {
+		got, err := parseTagList(test.value)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseTagList(%q) error = %v, want %q", test.value, err, test.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseTagList(%q) = %q, %v, want %q", test.value, got, err, test.want)
+		}
+	}
+}
+
+func TestParseContextName(t *testing.T) {
+	for value, want := range map[string]string{"": "", " @office ": "office", "büro": "büro"} {
+		if got, err := parseContextName(value); err != nil || got != want {
+			t.Errorf("parseContextName(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"two words", "+tag", "9am"} {
+		if _, err := parseContextName(value); err == nil {
+			t.Errorf("parseContextName(%q) succeeded", value)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// todo stats summarises the todos matching an optional filter expression.
+// Totals cover every todo, the rest are worked out from completed_at so
+// only count todos completed since it was recorded.
+
+// statsReport is written by -o json. Dates are YYYY-MM-DD, times RFC 3339.
+type statsReport struct {
+	Since       string          `json:"since"`
+	Until       string          `json:"until"`
+	Totals      statsTotals     `json:"totals"`
+	Added       int             `json:"added"`
+	Completed   int             `json:"completed"`
+	Bucket      string          `json:"bucket"`
+	Completions []statsBucket   `json:"completions"`
+	LastYear    []statsBucket   `json:"last_year"`
+	Average     []statsPriority `json:"average_time_to_complete"`
+	Streaks     statsStreaks    `json:"streaks"`
+}
+
+type statsTotals struct {
+	Total      int             `json:"total"`
+	Open       int             `json:"open"`
+	Completed  int             `json:"completed"`
+	ByPriority []statsPriority `json:"by_priority"`
+}
+
+// statsPriority is a total by priority, or the average hours taken to
+// complete todos of the priority
+type statsPriority struct {
+	Priority  int      `json:"priority"`
+	Open      *int     `json:"open,omitempty"`
+	Completed int      `json:"completed"`
+	Hours     *float64 `json:"hours,omitempty"`
+}
+
+// statsBucket is the number of todos completed in the day or week from
+// Start
+type statsBucket struct {
+	Start string `json:"start"`
+	Count int    `json:"count"`
+}
+
+type statsStreaks struct {
+	Current int `json:"current_days"`
+	Longest int `json:"longest_days"`
+}
+
+const (
+	sparkLevels = "▁▂▃▄▅▆▇█"
+	heatLevels  = "░▒▓█"
+	// Up to this many days or weeks are drawn as bars, more as a sparkline
+	maxBars = 14
+)
+
+// startOfWeek is the start of the week t is in, see weekStart
+func startOfWeek(t time.Time) time.Time {
+	t = startOfDay(t)
+	return t.AddDate(0, 0, -((int(t.Weekday()) - int(weekStart) + 7) % 7))
+}
+
+func statsCmd(d *DbTable, f *flag.FlagSet) {
+	since := f.String("since", "-4w", "Start of the window (e.g. -1w, 2026-01-01, eom)")
+	until := f.String("until", "now", "End of the window, a date means the end of that day")
+	by := f.String("by", "auto", "Count completions per day or week (auto | day | week)")
+	format := f.String("o", "table", "Output format (table | json)")
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: todo stats [flags] [filter expression]")
+		fmt.Fprintln(f.Output(), "  e.g. todo stats -since 2026-01-01 'tags = work'")
+		f.PrintDefaults()
+	}
+	f.Parse(os.Args[2:])
+	if *format != "table" && *format != "json" {
+		fmt.Printf("Error: invalid output format %q, expected table or json\n", *format)
+		os.Exit(1)
+	}
+	from, err := parseDate(*since)
+	if err != nil {
+		fmt.Println("Error reading -since: ", err)
+		os.Exit(1)
+	}
+	to, err := parseDate(*until)
+	if err != nil {
+		fmt.Println("Error reading -until: ", err)
+		os.Exit(1)
+	}
+	if to.Equal(startOfDay(to)) {
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.Before(to) {
+		fmt.Println("Error: -since must be before -until")
+		os.Exit(1)
+	}
+	filter, err := parseFilter(strings.Join(f.Args(), " "))
+	if err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+	if err = d.checkFilter(filter); err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+
+	bucket := *by
+	switch bucket {
+	case "auto":
+		bucket = "day"
+		if to.Sub(from) > 31*24*time.Hour {
+			bucket = "week"
+		}
+	case "day", "week":
+	default:
+		fmt.Printf("Error: invalid -by %q, expected auto, day or week\n", bucket)
+		os.Exit(1)
+	}
+
+	report := buildStats(d, filter, from, to, bucket)
+	if *format == "json" {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if err = e.Encode(report); err != nil {
+			fmt.Println("Error writing stats: ", err)
+			os.Exit(1)
+		}
+		return
+	}
+	printStats(newConsolePrint(d), report, from, to)
+}
+
+func buildStats(d *DbTable, filter *todoFilter, from time.Time, to time.Time, bucket string) statsReport {
+	report := statsReport{
+		Since:  from.Format(time.RFC3339),
+		Until:  to.Format(time.RFC3339),
+		Bucket: bucket,
+	}
+
+	byPriority := map[Priority]*statsPriority{}
+	for p := Priority(1); p <= 3; p++ {
+		byPriority[p] = &statsPriority{Priority: int(p), Open: new(int)}
+	}
+	for _, c := range d.getTodoCounts(filter) {
+		total, found := byPriority[c.priority]
+		if !found {
+			total = &statsPriority{Priority: int(c.priority), Open: new(int)}
+			byPriority[c.priority] = total
+		}
+		report.Totals.Total += c.count
+		if c.completed == 1 {
+			total.Completed += c.count
+			report.Totals.Completed += c.count
+		} else {
+			*total.Open += c.count
+			report.Totals.Open += c.count
+		}
+	}
+	for p := Priority(3); p >= 0; p-- {
+		if total, found := byPriority[p]; found {
+			report.Totals.ByPriority = append(report.Totals.ByPriority, *total)
+		}
+	}
+
+	added := *filter
+	added.and("created >= ? AND created < ?", from.Unix(), to.Unix())
+	var err error
+	if report.Added, err = d.countTodos(&added); err != nil {
+		// statsCmd has already checked the filter
+		panic(err)
+	}
+
+	// The heatmap and streaks look back a year whatever the window
+	yearStart := startOfWeek(to.AddDate(-1, 0, 1))
+	earliest := from
+	if yearStart.Before(earliest) {
+		earliest = yearStart
+	}
+	perDay := map[string]int{}
+	hours := map[Priority]float64{}
+	counted := map[Priority]int{}
+	for _, t := range d.getCompletions(filter, earliest, to) {
+		completed := time.Unix(t.completedAt, 0)
+		perDay[completed.Format("2006-01-02")]++
+		if completed.Before(from) {
+			continue
+		}
+		report.Completed++
+		if t.created != 0 && t.completedAt >= t.created {
+			hours[t.priority] += float64(t.completedAt-t.created) / 3600
+			counted[t.priority]++
+		}
+	}
+
+	// Completions in the window per bucket
+	start, step := startOfDay(from), 1
+	if bucket == "week" {
+		start, step = startOfWeek(from), 7
+	}
+	for day := start; day.Before(to); day = day.AddDate(0, 0, step) {
+		b := statsBucket{Start: day.Format("2006-01-02")}
+		for i := 0; i < step; i++ {
+			b.Count += perDay[day.AddDate(0, 0, i).Format("2006-01-02")]
+		}
+		report.Completions = append(report.Completions, b)
+	}
+	for day := yearStart; day.Before(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		report.LastYear = append(report.LastYear, statsBucket{Start: key, Count: perDay[key]})
+	}
+
+	for p := Priority(3); p >= 0; p-- {
+		if counted[p] == 0 {
+			continue
+		}
+		average := hours[p] / float64(counted[p])
+		report.Average = append(report.Average, statsPriority{Priority: int(p), Completed: counted[p], Hours: &average})
+	}
+
+	// A streak is a run of days with a completion. The current one can
+	// carry on today, so it counts from yesterday if nothing is done yet.
+	run := 0
+	for _, b := range report.LastYear {
+		if b.Count == 0 {
+			run = 0
+			continue
+		}
+		run++
+		report.Streaks.Longest = maxInt(report.Streaks.Longest, run)
+	}
+	days := report.LastYear
+	if len(days) > 0 && days[len(days)-1].Count == 0 {
+		days = days[:len(days)-1]
+	}
+	for i := len(days) - 1; i >= 0 && days[i].Count > 0; i-- {
+		report.Streaks.Current++
+	}
+	return report
+}
+
+// formatDuration shows how long a todo took in the largest sensible unit
+func formatDuration(hours float64) string {
+	switch {
+	case hours < 1:
+		return fmt.Sprintf("%.0fm", hours*60)
+	case hours < 48:
+		return fmt.Sprintf("%.1fh", hours)
+	default:
+		return fmt.Sprintf("%.1f days", hours/24)
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %v", n, unit)
+	}
+	return fmt.Sprintf("%d %vs", n, unit)
+}
+
+func printStats(c *ConsolePrint, report statsReport, from time.Time, to time.Time) {
+	color := c.color
+	heading := func(s string) {
+		fmt.Println(color["header"] + s + color["reset"])
+	}
+	c.printContextHeader()
+
+	heading("Priority  Open  Done  Total")
+	for _, p := range report.Totals.ByPriority {
+		fmt.Printf("%v%v%v  %4d  %4d  %5d\n", color[fmt.Sprintf("priority.%d", p.Priority)], padText(priorityMarker(Priority(p.Priority)), 8, false), color["reset"],
+			*p.Open, p.Completed, *p.Open+p.Completed)
+	}
+	fmt.Printf("%v  %4d  %4d  %5d\n\n", padText("Total", 8, false), report.Totals.Open, report.Totals.Completed, report.Totals.Total)
+
+	last := to.Add(-time.Second)
+	fmt.Printf("%v to %v: %v added, %v completed\n\n", from.Format("2 Jan 2006"), last.Format("2 Jan 2006"), report.Added, report.Completed)
+
+	heading("Completed per " + report.Bucket)
+	printCompletions(c, report)
+	fmt.Println()
+
+	heading("Last year")
+	printHeatmap(c, report.LastYear)
+	fmt.Println()
+
+	if len(report.Average) > 0 {
+		heading("Average time to complete")
+		for _, a := range report.Average {
+			fmt.Printf("%v%v%v  %v (%v)\n", color[fmt.Sprintf("priority.%d", a.Priority)], padText(priorityMarker(Priority(a.Priority)), 8, false), color["reset"],
+				formatDuration(*a.Hours), plural(a.Completed, "todo"))
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Streak: %v, longest in the last year %v\n", plural(report.Streaks.Current, "day"), plural(report.Streaks.Longest, "day"))
+}
+
+// printCompletions draws a bar per day or week, or a sparkline when there
+// are too many to fit a line each
+func printCompletions(c *ConsolePrint, report statsReport) {
+	most := 0
+	for _, b := range report.Completions {
+		most = maxInt(most, b.Count)
+	}
+	label := func(b statsBucket) string {
+		day, _ := time.ParseInLocation("2006-01-02", b.Start, time.Local)
+		return day.Format("Mon 2 Jan")
+	}
+	if len(report.Completions) <= maxBars {
+		barWidth := 40
+		if c.width > 0 {
+			barWidth = minInt(barWidth, maxInt(c.width-20, 5))
+		}
+		for _, b := range report.Completions {
+			length := 0
+			if most > 0 {
+				length = int(math.Ceil(float64(b.Count) * float64(barWidth) / float64(most)))
+			}
+			fmt.Printf("  %v  %v%v%v %d\n", padText(label(b), 10, false), c.color["text"], strings.Repeat("█", length), c.color["reset"], b.Count)
+		}
+		return
+	}
+
+	// The most recent that fit on the line
+	buckets := report.Completions
+	if c.width > 0 && len(buckets) > c.width-2 {
+		buckets = buckets[len(buckets)-maxInt(c.width-2, 1):]
+	}
+	levels := []rune(sparkLevels)
+	var line strings.Builder
+	for _, b := range buckets {
+		if b.Count == 0 || most == 0 {
+			line.WriteRune(' ')
+			continue
+		}
+		level := int(math.Ceil(float64(b.Count)*float64(len(levels))/float64(most))) - 1
+		line.WriteRune(levels[level])
+	}
+	fmt.Println("  " + c.color["text"] + line.String() + c.color["reset"])
+	first, last := label(buckets[0]), label(buckets[len(buckets)-1])
+	gap := maxInt(len(buckets)-displayWidth(first)-displayWidth(last), 1)
+	fmt.Printf("  %v%v%v  (most %d)\n", first, strings.Repeat(" ", gap), last, most)
+}
+
+// printHeatmap draws the last year as a column per week and a row per day
+// of the week, darker for more completions
+func printHeatmap(c *ConsolePrint, days []statsBucket) {
+	if len(days) == 0 {
+		return
+	}
+	weeks := (len(days) + 6) / 7
+	if c.width > 0 && weeks > c.width-5 {
+		// Only the most recent weeks that fit
+		drop := weeks - maxInt(c.width-5, 1)
+		days = days[drop*7:]
+		weeks -= drop
+	}
+	most := 0
+	for _, b := range days {
+		most = maxInt(most, b.Count)
+	}
+	levels := []rune(heatLevels)
+
+	// Month names above the week they start in, and above the first week
+	// when there is room before the next
+	months := []rune(strings.Repeat(" ", weeks+3))
+	label := func(w int, day string) {
+		if w > 0 && months[w-1] != ' ' {
+			return
+		}
+		date, _ := time.ParseInLocation("2006-01-02", day, time.Local)
+		copy(months[w:], []rune(date.Format("Jan")))
+	}
+	for w := 0; w < weeks; w++ {
+		for i := 0; i < 7 && w*7+i < len(days); i++ {
+			if strings.HasSuffix(days[w*7+i].Start, "-01") {
+				label(w, days[w*7+i].Start)
+			}
+		}
+	}
+	if strings.TrimSpace(string(months[:4])) == "" {
+		label(0, days[0].Start)
+	}
+	fmt.Println("     " + c.color["header"] + strings.TrimRight(string(months), " ") + c.color["reset"])
+
+	for row := 0; row < 7; row++ {
+		var line strings.Builder
+		line.WriteString(c.color["header"] + ((weekStart + time.Weekday(row)) % 7).String()[:3] + c.color["reset"] + "  ")
+		for w := 0; w < weeks; w++ {
+			i := w*7 + row
+			switch {
+			case i >= len(days):
+				line.WriteRune(' ')
+			case days[i].Count == 0:
+				line.WriteString(c.color["completed"] + "·" + c.color["reset"])
+			default:
+				level := int(math.Ceil(float64(days[i].Count)*float64(len(levels))/float64(most))) - 1
+				line.WriteString(c.color["text"] + string(levels[level]) + c.color["reset"])
+			}
+		}
+		fmt.Println(strings.TrimRight(line.String(), " "))
+	}
+	fmt.Println("     less " + c.color["completed"] + "·" + c.color["reset"] + c.color["text"] + heatLevels + c.color["reset"] + " more, most " + strconv.Itoa(most) + " a day")
+}
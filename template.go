@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// -format renders each todo with a text/template, either given inline
+//
+//	todo list -format '{{.ID}}\t{{.Name | trunc 40}}{{if .Overdue}} !{{end}}'
+//
+// or by name from <config dir>/templates/<name>.tmpl. A value containing
+// {{ is a template, anything else is a name. \t and \n in an inline
+// template are a tab and a newline.
+
+const templateDir = "templates"
+
+// templateTodo is the data a template is executed with. Times are zero
+// when not set.
+type templateTodo struct {
+	ID        int
+	Name      string
+	Content   string
+	Priority  int
+	Completed bool
+	Created   time.Time
+	Due       time.Time
+	Overdue   bool
+	Tags      []string
+	Context   string
+	Version   int
+	Urgency   float64
+}
+
+var templateColors = map[string]string{
+	"red":     "\033[31m",
+	"green":   "\033[32m",
+	"yellow":  "\033[33m",
+	"blue":    "\033[34m",
+	"magenta": "\033[35m",
+	"cyan":    "\033[36m",
+	"white":   "\033[37m",
+	"bold":    "\033[1m",
+	"dim":     "\033[2m",
+}
+
+// colorize styles s with a colour name or a role of the theme, such as
+// overdue. s is returned as it is when colour is off.
+func colorize(name string, s string) (string, error) {
+	code, found := templateColors[name]
+	if !found {
+		if code, found = currentStyles[name]; !found && colorOn() {
+			return "", fmt.Errorf("unknown colour %q", name)
+		}
+	}
+	if !colorOn() {
+		return s, nil
+	}
+	return code + s + resetStyle, nil
+}
+
+// templateFuncs are the helpers available to templates, written so that
+// the value comes last and they can be used in pipelines
+func templateFuncs(now time.Time) template.FuncMap {
+	funcs := template.FuncMap{
+		// trunc 20 shortens to 20 columns ending with …
+		"trunc": func(n int, s string) string {
+			if n <= 0 {
+				return s
+			}
+			return truncateText(s, n)
+		},
+		// pad 10 fills with spaces on the right, lpad on the left
+		"pad":   func(n int, s string) string { return padText(s, n, false) },
+		"lpad":  func(n int, s string) string { return padText(s, n, true) },
+		"color": colorize,
+		// date "2006-01-02" formats with a Go layout, "" for an unset time
+		"date": func(layout string, t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Format(layout)
+		},
+		// iso is RFC 3339, short leaves out midnight as the due column does
+		"iso": func(t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Format(time.RFC3339)
+		},
+		"short": func(t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return formatDate(t)
+		},
+		// ago describes t relative to now, e.g. "in 3 days" or "yesterday"
+		"ago": func(t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return relativeDate(t, now)
+		},
+		"join":  func(sep string, values []string) string { return strings.Join(values, sep) },
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}
+	// Each colour is also a function of its own, e.g. {{.Name | red}}
+	for name := range templateColors {
+		name := name
+		funcs[name] = func(s string) string {
+			s, _ = colorize(name, s)
+			return s
+		}
+	}
+	return funcs
+}
+
+// loadTemplate parses an inline template or reads a named one
+func loadTemplate(value string, config *Config) (*template.Template, error) {
+	text, name := value, "-format"
+	if strings.Contains(value, "{{") {
+		text = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n").Replace(value)
+	} else {
+		if strings.ContainsAny(value, `/\`) || value == "" {
+			return nil, fmt.Errorf("invalid template name %q", value)
+		}
+		path := filepath.Join(filepath.Dir(config.ConfigPath), templateDir, value+".tmpl")
+		b, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no template %q, expected a template containing {{ or a file %v", value, path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// A file ends with a newline which should not double up the line
+		// break between todos
+		text, name = strings.TrimSuffix(string(b), "\n"), value
+	}
+	return template.New(name).Funcs(templateFuncs(time.Now())).Option("missingkey=error").Parse(text)
+}
+
+func newTemplateTodo(t todo, scorer *urgencyScorer) templateTodo {
+	data := templateTodo{
+		ID:        t.id,
+		Name:      t.name,
+		Content:   t.content,
+		Priority:  int(t.priority),
+		Completed: t.completed == 1,
+		Overdue:   t.overdue(scorer.now),
+		Tags:      t.tags,
+		Context:   t.context,
+		Version:   t.version,
+		Urgency:   scorer.score(t),
+	}
+	if t.created != 0 {
+		data.Created = time.Unix(t.created, 0)
+	}
+	if due, hasDue := t.dueTime(); hasDue {
+		data.Due = due
+	}
+	return data
+}
+
+// executeTemplate writes each todo on a line of its own. Nothing is written
+// if the template fails for any todo.
+func executeTemplate(tmpl *template.Template, scorer *urgencyScorer, todos []todo) error {
+	var b bytes.Buffer
+	for _, t := range todos {
+		if err := tmpl.Execute(&b, newTemplateTodo(t, scorer)); err != nil {
+			return err
+		}
+		b.WriteString("\n")
+	}
+	_, err := b.WriteTo(os.Stdout)
+	return err
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Colour is used when --color, then NO_COLOR, then the color config key
+// allow it. auto, the default, colours output only when stdout is a
+// terminal.
+const (
+	configColor = "color"
+	configTheme = "theme"
+	themeDir    = "themes"
+	resetStyle  = "\033[0m"
+)
+
+var colorModes = []string{"auto", "always", "never"}
+
+// themeRoles are the parts of the output a theme colours
+var themeRoles = []struct {
+	name        string
+	description string
+}{
+	{"border", "table borders and dividers"},
+	{"header", "column titles"},
+	{"text", "todos"},
+	{"context", "the active context"},
+	{"priority.1", "low priority"},
+	{"priority.2", "medium priority"},
+	{"priority.3", "high priority"},
+	{"overdue", "todos past their due date"},
+	{"completed", "completed todos"},
+}
+
+// builtinThemes can be chosen by name. A theme file only needs the roles
+// it changes, the rest come from default.
+var builtinThemes = map[string]map[string]string{
+	"default": {
+		"border":     "blue",
+		"header":     "bold white",
+		"text":       "white",
+		"context":    "yellow",
+		"priority.1": "white",
+		"priority.2": "yellow",
+		"priority.3": "red",
+		"overdue":    "bold red",
+		"completed":  "bright-black",
+	},
+	"mono": {
+		"border":     "",
+		"header":     "bold",
+		"text":       "",
+		"context":    "bold",
+		"priority.1": "",
+		"priority.2": "",
+		"priority.3": "bold",
+		"overdue":    "underline",
+		"completed":  "dim",
+	},
+}
+
+var ansiColors = map[string]int{
+	"black": 30, "red": 31, "green": 32, "yellow": 33, "blue": 34, "magenta": 35, "cyan": 36, "white": 37,
+	"bright-black": 90, "gray": 90, "grey": 90, "bright-red": 91, "bright-green": 92, "bright-yellow": 93,
+	"bright-blue": 94, "bright-magenta": 95, "bright-cyan": 96, "bright-white": 97,
+}
+
+var ansiAttributes = map[string]int{"bold": 1, "dim": 2, "italic": 3, "underline": 4, "reverse": 7}
+
+// styles maps each theme role to its escape sequence. All are empty when
+// colour is off.
+type styles map[string]string
+
+// currentStyles are the styles of the output, set by setupColor
+var currentStyles = styles{}
+
+// colorOn reports whether output is coloured
+func colorOn() bool {
+	return currentStyles["reset"] != ""
+}
+
+// parseStyle turns a style such as "bold red", "208", "#ff8700" or
+// "white bg:blue" into an escape sequence. Numbers are 256 colour palette
+// entries and #rrggbb is truecolor.
+func parseStyle(spec string) (string, error) {
+	var codes []string
+	for _, word := range strings.Fields(strings.ToLower(spec)) {
+		background := strings.HasPrefix(word, "bg:")
+		word = strings.TrimPrefix(word, "bg:")
+		if code, found := ansiAttributes[word]; found && !background {
+			codes = append(codes, strconv.Itoa(code))
+			continue
+		}
+		code, err := colorCode(word, background)
+		if err != nil {
+			return "", err
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return "", nil
+	}
+	return "\033[" + strings.Join(codes, ";") + "m", nil
+}
+
+func colorCode(word string, background bool) (string, error) {
+	offset := 0
+	if background {
+		offset = 10
+	}
+	if code, found := ansiColors[word]; found {
+		return strconv.Itoa(code + offset), nil
+	}
+	if strings.HasPrefix(word, "#") && len(word) == 7 {
+		rgb, err := strconv.ParseUint(word[1:], 16, 32)
+		if err == nil {
+			return fmt.Sprintf("%d;2;%d;%d;%d", 38+offset, rgb>>16, rgb>>8&0xff, rgb&0xff), nil
+		}
+	}
+	if n, err := strconv.Atoi(word); err == nil && n >= 0 && n <= 255 {
+		return fmt.Sprintf("%d;5;%d", 38+offset, n), nil
+	}
+	return "", fmt.Errorf("unknown colour %q, expected a name, 0-255 or #rrggbb", word)
+}
+
+// loadTheme reads a built in theme or <config dir>/themes/<name>.json
+func loadTheme(name string, config *Config) (map[string]string, error) {
+	theme := map[string]string{}
+	for role, spec := range builtinThemes["default"] {
+		theme[role] = spec
+	}
+	if name == "" || name == "default" {
+		return theme, nil
+	}
+	changes, found := builtinThemes[name]
+	if !found {
+		if strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("invalid theme name %q", name)
+		}
+		path := filepath.Join(filepath.Dir(config.ConfigPath), themeDir, name+".json")
+		b, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no theme %q, expected a file %v", name, path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(b, &changes); err != nil {
+			return nil, fmt.Errorf("theme %v: %v", path, err)
+		}
+	}
+	for role, spec := range changes {
+		if _, known := theme[role]; !known {
+			return nil, fmt.Errorf("theme %q: unknown role %q", name, role)
+		}
+		theme[role] = spec
+	}
+	return theme, nil
+}
+
+func themeStyles(theme map[string]string) (styles, error) {
+	s := styles{"reset": resetStyle}
+	for role, spec := range theme {
+		style, err := parseStyle(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", role, err)
+		}
+		s[role] = style
+	}
+	return s, nil
+}
+
+// extractColorFlag removes --color <mode> or --color=<mode> given before
+// the subcommand from args, e.g. todo --color never list. After the
+// subcommand it is a flag of the command, see registerColorFlag.
+func extractColorFlag(args []string) ([]string, string, error) {
+	if len(args) == 0 {
+		return args, "", nil
+	}
+	mode := ""
+	i := 1
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--color" || arg == "-color" {
+			if i+1 == len(args) {
+				return nil, "", fmt.Errorf("--color needs a mode, expected %v", strings.Join(colorModes, ", "))
+			}
+			i++
+			mode = args[i]
+		} else if strings.HasPrefix(arg, "--color=") || strings.HasPrefix(arg, "-color=") {
+			mode = arg[strings.Index(arg, "=")+1:]
+		} else {
+			break
+		}
+		if !isColorMode(mode) {
+			return nil, "", fmt.Errorf("invalid colour mode %q, expected %v", mode, strings.Join(colorModes, ", "))
+		}
+	}
+	return append([]string{args[0]}, args[i:]...), mode, nil
+}
+
+// colorFlag is --color as a flag of a command. It is set up as soon as it
+// is parsed, before the command prints anything.
+type colorFlag struct {
+	config *Config
+}
+
+func (c colorFlag) String() string {
+	return ""
+}
+
+func (c colorFlag) Set(mode string) error {
+	if !isColorMode(mode) {
+		return fmt.Errorf("expected %v", strings.Join(colorModes, ", "))
+	}
+	setupColor(mode, c.config)
+	return nil
+}
+
+// registerColorFlag adds --color to the flags of a command. Every command
+// takes it so that it can be given to any of them.
+func registerColorFlag(f *flag.FlagSet, config *Config) {
+	f.Var(colorFlag{config}, "color", "Colour output (auto | always | never)")
+}
+
+func isColorMode(mode string) bool {
+	for _, m := range colorModes {
+		if mode == m {
+			return true
+		}
+	}
+	return false
+}
+
+// useColor decides whether to colour output from the --color flag,
+// NO_COLOR and the config
+func useColor(flagMode string, config *Config) (bool, error) {
+	mode := flagMode
+	if mode == "" && os.Getenv("NO_COLOR") != "" {
+		mode = "never"
+	}
+	if mode == "" {
+		mode = config.GetValue(configColor)
+	}
+	switch mode {
+	case "", "auto":
+		return term.IsTerminal(int(os.Stdout.Fd())), nil
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid colour mode %q, expected %v", mode, strings.Join(colorModes, ", "))
+}
+
+// setupColor sets currentStyles for the rest of the command. A broken
+// theme falls back to the default so that it can still be fixed with todo
+// config.
+func setupColor(flagMode string, config *Config) {
+	on, err := useColor(flagMode, config)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(1)
+	}
+	if !on {
+		currentStyles = styles{}
+		return
+	}
+	theme, err := loadTheme(config.GetValue(configTheme), config)
+	if err == nil {
+		currentStyles, err = themeStyles(theme)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading theme, using the default: ", err)
+		theme, _ = loadTheme("default", config)
+		currentStyles, _ = themeStyles(theme)
+	}
+}
+
+func themeNames(config *Config) []string {
+	var names []string
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(config.ConfigPath), themeDir, "*.json"))
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(file), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// themeCmd lists themes and previews them
+func themeCmd(f *flag.FlagSet, config *Config) {
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: todo theme list | preview [name]")
+		fmt.Fprintln(f.Output(), "  choose one with todo config set theme <name>")
+	}
+	f.Parse(os.Args[2:])
+	switch f.Arg(0) {
+	case "list":
+		active := config.GetValue(configTheme)
+		if active == "" {
+			active = "default"
+		}
+		for _, name := range themeNames(config) {
+			marker := " "
+			if name == active {
+				marker = "*"
+			}
+			fmt.Println(marker, name)
+		}
+	case "preview":
+		name := f.Arg(1)
+		if name == "" {
+			name = config.GetValue(configTheme)
+		}
+		theme, err := loadTheme(name, config)
+		if err != nil {
+			fmt.Println("Error loading theme: ", err)
+			os.Exit(1)
+		}
+		s, err := themeStyles(theme)
+		if err != nil {
+			fmt.Println("Error loading theme: ", err)
+			os.Exit(1)
+		}
+		if colorOn() {
+			currentStyles = s
+		}
+		previewTheme(theme)
+	default:
+		f.Usage()
+		os.Exit(1)
+	}
+}
+
+func previewTheme(theme map[string]string) {
+	for _, role := range themeRoles {
+		fmt.Printf("  %-11v %v%-26v%v %v\n", role.name, currentStyles[role.name], role.description, currentStyles["reset"], theme[role.name])
+	}
+	fmt.Println()
+
+	now := time.Now()
+	samples := []todo{
+		{id: 1, name: "Plan the release", content: "High priority", priority: 3},
+		{id: 2, name: "Review pull requests", content: "Medium priority", priority: 2},
+		{id: 3, name: "Tidy the backlog", content: "Low priority", priority: 1},
+		{id: 4, name: "Renew certificate", content: "Overdue", priority: 2, due: startOfDay(now).AddDate(0, 0, -2).Unix()},
+		{id: 5, name: "Write changelog", content: "Completed", priority: 2, completed: 1},
+	}
+	c := NewConsolePrint()
+	c.context = "preview"
+	c.printTodos(samples)
+}
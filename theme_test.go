@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractColorFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+		mode string
+	}{
+		{[]string{"todo"}, []string{"todo"}, ""},
+		{[]string{"todo", "list", "--color", "never"}, []string{"todo", "list", "--color", "never"}, ""},
+		{[]string{"todo", "--color", "never", "doctor"}, []string{"todo", "doctor"}, "never"},
+		{[]string{"todo", "-color=always", "list", "-p", "2"}, []string{"todo", "list", "-p", "2"}, "always"},
+		{[]string{"todo", "--color=auto", "--color", "never", "cal"}, []string{"todo", "cal"}, "never"},
+	}
+	for _, test := range tests {
+		got, mode, err := extractColorFlag(test.args)
+		if err != nil || !reflect.DeepEqual(got, test.want) || mode != test.mode {
+			t.Errorf("extractColorFlag(%q) = %q, %q, %v, want %q, %q", test.args, got, mode, err, test.want, test.mode)
+		}
+	}
+
+	for _, args := range [][]string{{"todo", "--color"}, {"todo", "--color", "list"}, {"todo", "--color=yes", "list"}} {
+		if _, _, err := extractColorFlag(args); err == nil {
+			t.Errorf("extractColorFlag(%q) succeeded", args)
+		}
+	}
+}
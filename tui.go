@@ -0,0 +1,721 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// todo tui is a full screen view of the same todos as todo list. It only
+// uses ANSI escape sequences, so works in any terminal including over SSH.
+
+const (
+	altScreenOn   = "\033[?1049h\033[?7l" // and no wrapping at the edge
+	altScreenOff  = "\033[?7h\033[?1049l"
+	cursorHide    = "\033[?25l"
+	cursorShow    = "\033[?25h"
+	cursorHome    = "\033[H"
+	clearLine     = "\033[K"
+	clearScreen   = "\033[2J"
+	selectedStyle = "\033[7m"
+)
+
+type tuiMode int
+
+const (
+	tuiBrowse tuiMode = iota
+	tuiFilter
+	tuiEdit
+	tuiAdd
+	tuiConfirm
+)
+
+// tuiField is a field that can be edited inline
+type tuiField struct {
+	title string
+	get   func(t todo) string
+	set   func(t *todo, value string) error
+}
+
+var tuiFields = []tuiField{
+	{"Name", func(t todo) string { return t.name }, func(t *todo, value string) error {
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("name cannot be empty")
+		}
+		t.name = value
+		return nil
+	}},
+	{"Content", func(t todo) string { return t.content }, func(t *todo, value string) error {
+		t.content = value
+		return nil
+	}},
+	{"Due", func(t todo) string {
+		if due, hasDue := t.dueTime(); hasDue {
+			return formatDate(due)
+		}
+		return ""
+	}, func(t *todo, value string) error {
+		if strings.TrimSpace(value) == "" {
+			value = "none"
+		}
+		due, err := parseDue(value)
+		if err != nil {
+			return err
+		}
+		t.due = due
+		return nil
+	}},
+	{"Tags", func(t todo) string { return joinTags(t.tags) }, func(t *todo, value string) error {
+		tags, err := parseTagList(value)
+		if err != nil {
+			return err
+		}
+		t.tags = tags
+		return nil
+	}},
+	{"Context", func(t todo) string { return t.context }, func(t *todo, value string) error {
+		context, err := parseContextName(value)
+		if err != nil {
+			return err
+		}
+		t.context = context
+		return nil
+	}},
+}
+
+var tuiHelp = []string{
+	"j/k ↓/↑      move          g/G home/end  first/last",
+	"ctrl-d/u     page down/up  /             filter as you type",
+	"space        complete      +/-           raise/lower priority",
+	"e/enter      edit inline   tab           next field while editing",
+	"a            add           d             delete",
+	"r            reload        esc           clear filter",
+	"?            help          q             quit",
+}
+
+type tui struct {
+	d        *DbTable
+	filter   *todoFilter
+	scorer   *urgencyScorer
+	print    ConsolePrint
+	todos    []todo // every todo matching the filter
+	shown    []todo // the todos matching what has been typed after /
+	cursor   int
+	offset   int
+	mode     tuiMode
+	query    lineEditor
+	input    lineEditor
+	field    int
+	editing  todo
+	message  string
+	help     bool
+	quitting bool
+}
+
+func tuiCmd(d *DbTable, f *flag.FlagSet, config *Config) {
+	o := listFlags(f)
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: todo tui [@view] [flags] [filter expression]")
+		fmt.Fprintln(f.Output(), "  press ? in the interface for the keys")
+		f.PrintDefaults()
+	}
+	args, viewFilter, err := expandView(os.Args[2:], config)
+	if err != nil {
+		fmt.Println("Error reading view: ", err)
+		os.Exit(1)
+	}
+	f.Parse(args)
+	format := o.output.load(config)
+	filter, err := o.buildFilter(f, viewFilter)
+	if err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+	if err = d.checkFilter(filter); err != nil {
+		fmt.Println("Error in filter: ", err)
+		os.Exit(1)
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Println("Error: todo tui needs a terminal, use todo list in scripts")
+		os.Exit(1)
+	}
+
+	ui := &tui{d: d, filter: filter}
+	if filter.sortsByUrgency() {
+		if ui.scorer, err = newUrgencyScorer(config); err != nil {
+			fmt.Println("Error reading urgency coefficients: ", err)
+			os.Exit(1)
+		}
+	}
+	ui.print = ConsolePrint{columns: format.columns, color: currentStyles, mode: "compact"}
+	if d.context != nil {
+		ui.print.context = d.context.name
+	}
+	if err = ui.run(); err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(1)
+	}
+}
+
+// run takes over the terminal until the user quits
+func (ui *tui) run() error {
+	restoreOutput, err := enableVirtualTerminal()
+	if err != nil {
+		return err
+	}
+	defer restoreOutput()
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
+	os.Stdout.WriteString(altScreenOn + cursorHide)
+	defer os.Stdout.WriteString(cursorShow + altScreenOff)
+
+	resized, stopResize := resizeEvents()
+	defer stopResize()
+	keys := readKeys()
+
+	ui.reload(0)
+	ui.draw()
+	for !ui.quitting {
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			ui.handleKey(key)
+		case <-resized:
+			os.Stdout.WriteString(clearScreen)
+		}
+		ui.draw()
+	}
+	return nil
+}
+
+// reload reads the todos again, keeping the cursor on the todo with id if
+// it is still there
+func (ui *tui) reload(id int) {
+	todos := ui.d.queryTodos(ui.filter, -1)
+	if ui.scorer != nil {
+		ui.scorer.sortByUrgency(todos)
+	}
+	ui.todos = todos
+	ui.applyQuery(id)
+}
+
+// applyQuery picks the todos containing every word typed after /. +tag and
+// @context match tags and the context.
+func (ui *tui) applyQuery(id int) {
+	words := strings.Fields(strings.ToLower(ui.query.String()))
+	ui.shown = nil
+	for _, t := range ui.todos {
+		text := strings.ToLower(t.name + " " + t.content + " +" + strings.Join(t.tags, " +") + " @" + t.context)
+		matches := true
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			ui.shown = append(ui.shown, t)
+		}
+	}
+	ui.cursor = minInt(ui.cursor, maxInt(len(ui.shown)-1, 0))
+	for i, t := range ui.shown {
+		if t.id == id {
+			ui.cursor = i
+		}
+	}
+}
+
+func (ui *tui) selected() (todo, bool) {
+	if ui.cursor >= len(ui.shown) {
+		return todo{}, false
+	}
+	return ui.shown[ui.cursor], true
+}
+
+// replace shows t in place of the todo it was read from, without sorting
+// again so that the cursor stays where it is
+func (ui *tui) replace(t todo) {
+	for _, list := range [][]todo{ui.todos, ui.shown} {
+		for i := range list {
+			if list[i].id == t.id {
+				list[i] = t
+			}
+		}
+	}
+}
+
+// save writes t and reads it back
+func (ui *tui) save(t todo) bool {
+	if err := ui.d.updateTodoById(t.id, t); err != nil {
+		if err == errConflict {
+			ui.message = "Changed elsewhere, reloaded"
+			ui.reload(t.id)
+		} else {
+			ui.message = "Error updating todo: " + err.Error()
+		}
+		return false
+	}
+	ui.replace(ui.d.getTodoById(t.id))
+	return true
+}
+
+func (ui *tui) listHeight() int {
+	_, height := tuiSize()
+	return maxInt(height-3, 1)
+}
+
+func (ui *tui) move(by int) {
+	ui.cursor = maxInt(minInt(ui.cursor+by, len(ui.shown)-1), 0)
+}
+
+func (ui *tui) handleKey(key string) {
+	if key == "ctrl-c" {
+		ui.quitting = true
+		return
+	}
+	switch ui.mode {
+	case tuiFilter:
+		ui.filterKey(key)
+	case tuiEdit, tuiAdd:
+		ui.editKey(key)
+	case tuiConfirm:
+		ui.confirmKey(key)
+	default:
+		ui.browseKey(key)
+	}
+}
+
+func (ui *tui) browseKey(key string) {
+	ui.message = ""
+	if ui.help && key != "?" {
+		ui.help = false
+	}
+	switch key {
+	case "q":
+		ui.quitting = true
+	case "j", "down", "ctrl-n":
+		ui.move(1)
+	case "k", "up", "ctrl-p":
+		ui.move(-1)
+	case "g", "home":
+		ui.cursor = 0
+	case "G", "end":
+		ui.move(len(ui.shown))
+	case "ctrl-d", "pgdn":
+		ui.move(ui.listHeight())
+	case "ctrl-u", "pgup":
+		ui.move(-ui.listHeight())
+	case "/":
+		ui.mode = tuiFilter
+	case "esc":
+		if ui.query.String() != "" {
+			t, _ := ui.selected()
+			ui.query.set("")
+			ui.applyQuery(t.id)
+		}
+	case "?":
+		ui.help = !ui.help
+	case "r", "ctrl-l":
+		t, _ := ui.selected()
+		ui.reload(t.id)
+		os.Stdout.WriteString(clearScreen)
+	case " ", "x":
+		if t, found := ui.selected(); found {
+			t.completed = 1 - t.completed
+			ui.save(t)
+		}
+	case "+", "=", "-":
+		if t, found := ui.selected(); found {
+			p := t.priority + 1
+			if key == "-" {
+				p = t.priority - 1
+			}
+			if p < 1 || p > 3 {
+				return
+			}
+			t.priority = p
+			ui.save(t)
+		}
+	case "e", "enter":
+		if t, found := ui.selected(); found {
+			ui.mode, ui.editing, ui.field = tuiEdit, t, 0
+			ui.input.set(tuiFields[0].get(t))
+		}
+	case "a":
+		ui.mode = tuiAdd
+		ui.input.set("")
+	case "d", "delete":
+		if _, found := ui.selected(); found {
+			ui.mode = tuiConfirm
+		}
+	}
+}
+
+func (ui *tui) filterKey(key string) {
+	switch key {
+	case "enter":
+		ui.mode = tuiBrowse
+		return
+	case "down", "up":
+		// Keep the filter and move through what it matches
+		ui.mode = tuiBrowse
+		ui.browseKey(key)
+		return
+	case "esc":
+		ui.query.set("")
+		ui.mode = tuiBrowse
+	default:
+		ui.query.handleKey(key)
+	}
+	t, _ := ui.selected()
+	ui.applyQuery(t.id)
+}
+
+// editKey edits the fields of the selected todo one at a time, tab moving
+// to the next. enter saves every field, esc leaves the todo as it was.
+func (ui *tui) editKey(key string) {
+	ui.message = ""
+	switch key {
+	case "esc":
+		ui.mode = tuiBrowse
+		return
+	case "enter":
+		if ui.mode == tuiAdd {
+			ui.addTodo()
+			return
+		}
+		if err := tuiFields[ui.field].set(&ui.editing, ui.input.String()); err != nil {
+			ui.message = err.Error()
+			return
+		}
+		ui.mode = tuiBrowse
+		ui.save(ui.editing)
+	case "tab", "backtab":
+		if ui.mode == tuiAdd {
+			return
+		}
+		if err := tuiFields[ui.field].set(&ui.editing, ui.input.String()); err != nil {
+			ui.message = err.Error()
+			return
+		}
+		step := 1
+		if key == "backtab" {
+			step = len(tuiFields) - 1
+		}
+		ui.field = (ui.field + step) % len(tuiFields)
+		ui.input.set(tuiFields[ui.field].get(ui.editing))
+	default:
+		ui.input.handleKey(key)
+	}
+}
+
+// addTodo adds a todo from quick add syntax, e.g. "Call Sam +phone !2"
+func (ui *tui) addTodo() {
+	name := strings.TrimSpace(ui.input.String())
+	ui.mode = tuiBrowse
+	if name == "" {
+		return
+	}
+	t, _, err := (&addOptions{priority: 1}).build(ui.d, name)
+	if err != nil {
+		ui.message = "Error reading todo name: " + err.Error()
+		return
+	}
+	id, err := ui.d.insertTodo(t)
+	if err != nil {
+		ui.message = "Error inserting todo: " + err.Error()
+		return
+	}
+	ui.reload(id)
+	if current, _ := ui.selected(); current.id != id {
+		ui.message = fmt.Sprintf("Added todo %d, hidden by the filter", id)
+	}
+}
+
+func (ui *tui) confirmKey(key string) {
+	ui.mode = tuiBrowse
+	t, found := ui.selected()
+	if key != "y" || !found {
+		return
+	}
+	if _, err := ui.d.deleteTodoById(t.id); err != nil {
+		ui.message = "Error deleting todo: " + err.Error()
+		return
+	}
+	ui.message = fmt.Sprintf("Deleted todo %d", t.id)
+	ui.reload(0)
+}
+
+// draw writes the whole screen at once so that it does not flicker
+func (ui *tui) draw() {
+	width, height := tuiSize()
+	c := ui.print
+	c.width = width
+	color := c.color
+	var b strings.Builder
+	b.WriteString(cursorHome)
+	line := func(s string) {
+		b.WriteString(s + color["reset"] + clearLine + "\r\n")
+	}
+
+	title := fmt.Sprintf(" todo  %d of %d", len(ui.shown), len(ui.todos))
+	if c.context != "" {
+		title += "  context: " + c.context
+	}
+	if q := ui.query.String(); q != "" && ui.mode != tuiFilter {
+		title += "  /" + q
+	}
+	line(color["header"] + padText(truncateText(title, width-8), width-8, false) + truncateText("? help", minInt(width, 8)))
+
+	rows := ui.listHeight()
+	if ui.cursor < ui.offset {
+		ui.offset = ui.cursor
+	}
+	if ui.cursor >= ui.offset+rows {
+		ui.offset = ui.cursor - rows + 1
+	}
+	ui.offset = maxInt(minInt(ui.offset, len(ui.shown)-rows), 0)
+
+	widths := c.layout(ui.shown)
+	cells := func(value func(col tableColumn) string, style func(col tableColumn) string) string {
+		var r strings.Builder
+		for i, col := range c.columns {
+			cell := padText(truncateText(value(col), widths[i]), widths[i], col.numeric)
+			if i < len(c.columns)-1 {
+				cell += "  "
+			}
+			if s := style(col); s != "" {
+				cell = s + cell + resetStyle
+			}
+			r.WriteString(cell)
+		}
+		return r.String()
+	}
+	line(cells(func(col tableColumn) string { return col.title }, func(tableColumn) string { return color["header"] }))
+
+	for i := 0; i < rows; i++ {
+		switch {
+		case ui.help:
+			if i < len(tuiHelp) {
+				// Lines wider than the screen are cut off by the terminal
+				line(" " + tuiHelp[i])
+			} else {
+				line("")
+			}
+		case ui.offset+i < len(ui.shown):
+			t := ui.shown[ui.offset+i]
+			selected := ui.offset+i == ui.cursor
+			line(cells(func(col tableColumn) string { return col.value(t) }, func(col tableColumn) string {
+				if selected {
+					return c.todoStyle(t, col) + selectedStyle
+				}
+				return c.todoStyle(t, col)
+			}) + fillSelected(selected, width, widths, c.overhead()))
+		case i == 0 && len(ui.shown) == 0:
+			line(" No todos, press a to add one")
+		default:
+			line("")
+		}
+	}
+
+	status, cursor := ui.statusLine(width)
+	b.WriteString(truncateText(status, width) + clearLine)
+	if cursor >= 0 {
+		fmt.Fprintf(&b, "\033[%d;%dH%v", height, minInt(cursor+1, width), cursorShow)
+	} else {
+		b.WriteString(cursorHide)
+	}
+	os.Stdout.WriteString(b.String())
+}
+
+// fillSelected carries the highlight of the selected row to the edge of the
+// screen
+func fillSelected(selected bool, width int, widths []int, overhead int) string {
+	if !selected {
+		return ""
+	}
+	used := overhead
+	for _, w := range widths {
+		used += w
+	}
+	return selectedStyle + strings.Repeat(" ", maxInt(width-used, 0)) + resetStyle
+}
+
+// statusLine is the bottom line of the screen and the column of the cursor
+// on it, -1 when the cursor is hidden
+func (ui *tui) statusLine(width int) (string, int) {
+	switch ui.mode {
+	case tuiFilter:
+		return ui.query.prompt("/", width)
+	case tuiEdit:
+		return ui.input.prompt(tuiFields[ui.field].title+": ", width)
+	case tuiAdd:
+		return ui.input.prompt("Add: ", width)
+	case tuiConfirm:
+		t, _ := ui.selected()
+		return fmt.Sprintf("Delete todo %d %q? (y/n)", t.id, t.name), -1
+	}
+	if ui.message != "" {
+		return ui.message, -1
+	}
+	return "", -1
+}
+
+// lineEditor is a line of text being typed
+type lineEditor struct {
+	text []rune
+	pos  int
+}
+
+func (l *lineEditor) String() string {
+	return string(l.text)
+}
+
+func (l *lineEditor) set(s string) {
+	l.text = []rune(s)
+	l.pos = len(l.text)
+}
+
+func (l *lineEditor) handleKey(key string) {
+	switch key {
+	case "left":
+		l.pos = maxInt(l.pos-1, 0)
+	case "right":
+		l.pos = minInt(l.pos+1, len(l.text))
+	case "home", "ctrl-a":
+		l.pos = 0
+	case "end", "ctrl-e":
+		l.pos = len(l.text)
+	case "backspace":
+		if l.pos > 0 {
+			l.text = append(l.text[:l.pos-1], l.text[l.pos:]...)
+			l.pos--
+		}
+	case "delete":
+		if l.pos < len(l.text) {
+			l.text = append(l.text[:l.pos], l.text[l.pos+1:]...)
+		}
+	case "ctrl-u":
+		l.text = l.text[l.pos:]
+		l.pos = 0
+	case "ctrl-w":
+		start := l.pos
+		for start > 0 && l.text[start-1] == ' ' {
+			start--
+		}
+		for start > 0 && l.text[start-1] != ' ' {
+			start--
+		}
+		l.text = append(l.text[:start], l.text[l.pos:]...)
+		l.pos = start
+	default:
+		if r, size := utf8.DecodeRuneInString(key); size == len(key) && r >= ' ' {
+			l.text = append(l.text[:l.pos], append([]rune{r}, l.text[l.pos:]...)...)
+			l.pos++
+		}
+	}
+}
+
+// prompt shows the text after label, scrolled so that the cursor is on
+// screen
+func (l *lineEditor) prompt(label string, width int) (string, int) {
+	before := label + string(l.text[:l.pos])
+	start := 0
+	for displayWidth(before) >= width && start < l.pos {
+		start++
+		before = label + "…" + string(l.text[start:l.pos])
+	}
+	return before + string(l.text[l.pos:]), displayWidth(before)
+}
+
+// tuiSize is the current size of the terminal, which changes as it is
+// resized
+func tuiSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return defaultWidth, 24
+	}
+	return width, height
+}
+
+// readKeys sends each key pressed as its character or a name such as up,
+// enter or ctrl-d
+func readKeys() <-chan string {
+	keys := make(chan string)
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 256)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			for _, key := range parseKeys(buf[:n]) {
+				keys <- key
+			}
+		}
+	}()
+	return keys
+}
+
+var controlKeys = map[byte]string{
+	'\r': "enter", '\n': "enter", '\t': "tab", 0x7f: "backspace", 0x08: "backspace",
+	0x01: "ctrl-a", 0x03: "ctrl-c", 0x04: "ctrl-d", 0x05: "ctrl-e", 0x0c: "ctrl-l",
+	0x0e: "ctrl-n", 0x10: "ctrl-p", 0x15: "ctrl-u", 0x17: "ctrl-w",
+}
+
+// escapeKeys are the final bytes of the sequences sent by special keys, and
+// their number for the sequences ending in ~
+var escapeKeys = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left", "H": "home", "F": "end", "Z": "backtab",
+	"1~": "home", "7~": "home", "4~": "end", "8~": "end", "3~": "delete", "5~": "pgup", "6~": "pgdn",
+}
+
+// parseKeys splits what the terminal sent into keys. An escape on its own
+// is the escape key, as terminals send a whole sequence in one write.
+func parseKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		if b[0] == 0x1b {
+			if len(b) > 1 && (b[1] == '[' || b[1] == 'O') {
+				end := 2
+				for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+					end++
+				}
+				if end < len(b) {
+					if key, found := escapeKeys[string(b[2:end+1])]; found {
+						keys = append(keys, key)
+					} else if key, found := escapeKeys[string(b[end])]; found {
+						// Modifiers such as shift, e.g. \033[1;2A
+						keys = append(keys, key)
+					}
+					b = b[end+1:]
+					continue
+				}
+			}
+			keys = append(keys, "esc")
+			b = b[1:]
+			continue
+		}
+		if b[0] < ' ' || b[0] == 0x7f {
+			if key, found := controlKeys[b[0]]; found {
+				keys = append(keys, key)
+			}
+			b = b[1:]
+			continue
+		}
+		r, size := utf8.DecodeRune(b)
+		keys = append(keys, string(r))
+		b = b[size:]
+	}
+	return keys
+}
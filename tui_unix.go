@@ -0,0 +1,35 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// resizeEvents signals each time the terminal is resized, which unix
+// terminals report with SIGWINCH, including over SSH
+func resizeEvents() (<-chan struct{}, func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	resized := make(chan struct{}, 1)
+	go func() {
+		for range signals {
+			select {
+			case resized <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return resized, func() {
+		signal.Stop(signals)
+		close(signals)
+	}
+}
+
+// enableVirtualTerminal is only needed on Windows, unix terminals always
+// understand escape sequences
+func enableVirtualTerminal() (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,54 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/term"
+)
+
+// resizeEvents signals each time the console is resized. Windows has no
+// signal for it so the size is checked a few times a second.
+func resizeEvents() (<-chan struct{}, func()) {
+	resized := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		width, height, _ := term.GetSize(int(os.Stdout.Fd()))
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				w, h, err := term.GetSize(int(os.Stdout.Fd()))
+				if err != nil || (w == width && h == height) {
+					continue
+				}
+				width, height = w, h
+				select {
+				case resized <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return resized, func() { close(done) }
+}
+
+// enableVirtualTerminal turns on escape sequences in the console, returning
+// a function that restores its previous mode
+func enableVirtualTerminal() (func(), error) {
+	handle := windows.Handle(os.Stdout.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return nil, err
+	}
+	if err := windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		return nil, err
+	}
+	return func() { windows.SetConsoleMode(handle, mode) }, nil
+}
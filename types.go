@@ -1,5 +1,11 @@
 package main
 
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
 type Priority int
 
 const (
@@ -14,6 +20,55 @@ type todo struct {
 	content   string
 	priority  Priority
 	completed int
+	version   int     // incremented on every update to detect concurrent edits
+	position  float64 // manual order within a priority
+	created   int64   // unix seconds, 0 for todos added before it was recorded
+	due       int64   // unix seconds, 0 when there is no due date
+	tags      []string
+	context   string // where the todo can be done, e.g. office
+	// unix seconds, 0 when incomplete or completed before it was recorded
+	completedAt int64
+}
+
+// dueTime returns the due date and whether one is set
+func (t todo) dueTime() (time.Time, bool) {
+	if t.due == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(t.due, 0), true
+}
+
+// dueBy is the moment a todo becomes overdue. A due date without a time of
+// day is due by the end of that day.
+func (t todo) dueBy() time.Time {
+	due, _ := t.dueTime()
+	if due.Hour() == 0 && due.Minute() == 0 && due.Second() == 0 {
+		return due.AddDate(0, 0, 1)
+	}
+	return due
+}
+
+func (t todo) overdue(now time.Time) bool {
+	_, hasDue := t.dueTime()
+	return hasDue && t.completed == 0 && now.After(t.dueBy())
+}
+
+// joinTags stores tags as a space separated, sorted list without repeats
+func joinTags(tags []string) string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, tag := range tags {
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			unique = append(unique, tag)
+		}
+	}
+	sort.Strings(unique)
+	return strings.Join(unique, " ")
+}
+
+func splitTags(tags string) []string {
+	return strings.Fields(tags)
 }
 
 // type db struct {
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// urgencyFactor contributes value(t) * coefficient to a todo's urgency.
+// value is normalised to 0 - 1 so coefficients are comparable.
+type urgencyFactor struct {
+	name        string
+	coefficient float64
+	description string
+	value       func(t todo, now time.Time) float64
+}
+
+// urgencyFactors are the defaults, each can be overridden with
+// todo config set urgency.<name> <coefficient>
+var urgencyFactors = []urgencyFactor{
+	{"priority", 6.0, "priority / 3", func(t todo, now time.Time) float64 {
+		return math.Max(0, math.Min(float64(t.priority)/3, 1))
+	}},
+	{"due", 12.0, "0.2 two weeks or more out rising to 1 a week overdue", func(t todo, now time.Time) float64 {
+		if _, hasDue := t.dueTime(); !hasDue {
+			return 0
+		}
+		days := t.dueBy().Sub(now).Hours() / 24
+		switch {
+		case days <= -7:
+			return 1
+		case days >= 14:
+			return 0.2
+		default:
+			return 0.2 + 0.8*(14-days)/21
+		}
+	}},
+	{"age", 2.0, "days since added / 365", func(t todo, now time.Time) float64 {
+		if t.created == 0 {
+			return 0
+		}
+		days := now.Sub(time.Unix(t.created, 0)).Hours() / 24
+		return math.Max(0, math.Min(days/365, 1))
+	}},
+}
+
+const urgencyConfigPrefix = "urgency."
+
+// Todos with a tag or in a context can be given extra urgency with
+// todo config set urgency.tag.<name> <coefficient>, or urgency.context.<name>.
+// There are none by default.
+const (
+	urgencyTagPrefix     = urgencyConfigPrefix + "tag."
+	urgencyContextPrefix = urgencyConfigPrefix + "context."
+)
+
+// urgencyTerm is one factor's contribution to a score
+type urgencyTerm struct {
+	factor      urgencyFactor
+	value       float64
+	coefficient float64
+}
+
+func (u urgencyTerm) score() float64 {
+	return u.value * u.coefficient
+}
+
+type urgencyScorer struct {
+	coefficients map[string]float64
+	tags         map[string]float64
+	contexts     map[string]float64
+	now          time.Time
+}
+
+func newUrgencyScorer(config *Config) (*urgencyScorer, error) {
+	u := &urgencyScorer{
+		coefficients: map[string]float64{},
+		tags:         map[string]float64{},
+		contexts:     map[string]float64{},
+		now:          time.Now(),
+	}
+	for _, f := range urgencyFactors {
+		u.coefficients[f.name] = f.coefficient
+		value := config.GetValue(urgencyConfigPrefix + f.name)
+		if value == "" {
+			continue
+		}
+		coefficient, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v%v in config: %q is not a number", urgencyConfigPrefix, f.name, value)
+		}
+		u.coefficients[f.name] = coefficient
+	}
+	for key := range config.GetConfig() {
+		named := u.tags
+		name := strings.TrimPrefix(key, urgencyTagPrefix)
+		if name == key {
+			named = u.contexts
+			if name = strings.TrimPrefix(key, urgencyContextPrefix); name == key {
+				continue
+			}
+		}
+		// A deleted key is left in the config as ""
+		value := config.GetValue(key)
+		if value == "" {
+			continue
+		}
+		coefficient, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v in config: %q is not a number", key, value)
+		}
+		named[name] = coefficient
+	}
+	return u, nil
+}
+
+// terms breaks the urgency of t down by factor
+func (u *urgencyScorer) terms(t todo) []urgencyTerm {
+	var terms []urgencyTerm
+	for _, f := range urgencyFactors {
+		terms = append(terms, urgencyTerm{factor: f, value: f.value(t, u.now), coefficient: u.coefficients[f.name]})
+	}
+	// Tags and contexts are only listed when the todo has them
+	for _, tag := range t.tags {
+		if coefficient, found := u.tags[tag]; found {
+			f := urgencyFactor{name: "tag." + tag, description: "has tag " + tag}
+			terms = append(terms, urgencyTerm{factor: f, value: 1, coefficient: coefficient})
+		}
+	}
+	if coefficient, found := u.contexts[t.context]; found && t.context != "" {
+		f := urgencyFactor{name: "context." + t.context, description: "in context " + t.context}
+		terms = append(terms, urgencyTerm{factor: f, value: 1, coefficient: coefficient})
+	}
+	return terms
+}
+
+func (u *urgencyScorer) score(t todo) float64 {
+	total := 0.0
+	for _, term := range u.terms(t) {
+		total += term.score()
+	}
+	return total
+}
+
+// sortByUrgency orders todos most urgent first, keeping the existing order
+// for equal scores
+func (u *urgencyScorer) sortByUrgency(todos []todo) {
+	scores := map[int]float64{}
+	for _, t := range todos {
+		scores[t.id] = u.score(t)
+	}
+	sort.SliceStable(todos, func(i, j int) bool {
+		return scores[todos[i].id] > scores[todos[j].id]
+	})
+}
+
+func (u *urgencyScorer) printBreakdown(t todo) {
+	fmt.Printf("Urgency: %.2f\n", u.score(t))
+	for _, term := range u.terms(t) {
+		fmt.Printf("  %-12v %5.2f x %5.2f = %5.2f  (%v)\n",
+			term.factor.name, term.value, term.coefficient, term.score(), term.factor.description)
+	}
+}
+
+// validUrgencyKey checks a config key of the form urgency.<factor>,
+// urgency.tag.<name> or urgency.context.<name> with a number
+func validUrgencyKey(key string, value string) error {
+	name := strings.TrimPrefix(key, urgencyConfigPrefix)
+	found := false
+	for _, prefix := range []string{urgencyTagPrefix, urgencyContextPrefix} {
+		if strings.HasPrefix(key, prefix) {
+			if strings.TrimPrefix(key, prefix) == "" {
+				return fmt.Errorf("%v needs a name, e.g. %vwork", prefix, prefix)
+			}
+			found = true
+		}
+	}
+	var names []string
+	for _, f := range urgencyFactors {
+		names = append(names, f.name)
+		if f.name == name {
+			found = true
+		}
+	}
+	if !found {
+		names = append(names, "tag.<name>", "context.<name>")
+		return fmt.Errorf("unknown urgency factor %q, expected one of %v", name, strings.Join(names, ", "))
+	}
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return fmt.Errorf("urgency coefficient %q is not a number", value)
+	}
+	return nil
+}
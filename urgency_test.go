@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewUrgencyScorer(t *testing.T) {
+	config := &Config{config: map[string]interface{}{
+		"urgency.priority":     "2",
+		"urgency.age":          "",
+		"urgency.tag.work":     "3.5",
+		"urgency.context.home": "-1",
+		"colour":               "never",
+	}}
+	u, err := newUrgencyScorer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.coefficients["priority"] != 2 || u.coefficients["age"] != 2 || u.coefficients["due"] != 12 {
+		t.Errorf("coefficients = %v", u.coefficients)
+	}
+	if !reflect.DeepEqual(u.tags, map[string]float64{"work": 3.5}) || !reflect.DeepEqual(u.contexts, map[string]float64{"home": -1}) {
+		t.Errorf("tags = %v, contexts = %v", u.tags, u.contexts)
+	}
+}
+
+// todo config delete leaves the key with an empty value, which is the same as
+// not being set
+func TestNewUrgencyScorerDeletedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := &Config{ConfigPath: path, config: map[string]interface{}{}}
+	for _, key := range []string{"urgency.due", "urgency.tag.work", "urgency.context.home"} {
+		if err := config.Set(key, "1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := config.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config, err := ReadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := newUrgencyScorer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.coefficients["due"] != 12 || len(u.tags) != 0 || len(u.contexts) != 0 {
+		t.Errorf("coefficients = %v, tags = %v, contexts = %v", u.coefficients, u.tags, u.contexts)
+	}
+}
+
+func TestNewUrgencyScorerErrors(t *testing.T) {
+	tests := []struct {
+		key, value string
+		msg        string
+	}{
+		{"urgency.due", "soon", `invalid urgency.due in config: "soon" is not a number`},
+		{"urgency.tag.work", "high", `invalid urgency.tag.work in config: "high" is not a number`},
+		{"urgency.context.home", "1,5", `invalid urgency.context.home in config: "1,5" is not a number`},
+	}
+	for _, test := range tests {
+		config := &Config{config: map[string]interface{}{test.key: test.value}}
+		if _, err := newUrgencyScorer(config); err == nil || err.Error() != test.msg {
+			t.Errorf("newUrgencyScorer with %v = %q error = %v, want %q", test.key, test.value, err, test.msg)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Saved views are kept in the user config as
+//
+//	"views": {"standup": {"flags": ["-s", "incomplete", "-l", "20"], "filter": "priority = 3"}}
+//	"defaultView": "standup"
+const (
+	configViews       = "views"
+	configDefaultView = "defaultView"
+)
+
+var viewName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type listView struct {
+	flags  []string
+	filter string
+}
+
+func getViews(config *Config) map[string]listView {
+	views := map[string]listView{}
+	saved, _ := config.GetConfig()[configViews].(map[string]interface{})
+	for name, value := range saved {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var v listView
+		flags, _ := fields["flags"].([]interface{})
+		for _, flag := range flags {
+			if s, ok := flag.(string); ok {
+				v.flags = append(v.flags, s)
+			}
+		}
+		v.filter, _ = fields["filter"].(string)
+		views[name] = v
+	}
+	return views
+}
+
+func writeViews(config *Config, views map[string]listView) error {
+	saved := map[string]interface{}{}
+	for name, v := range views {
+		saved[name] = map[string]interface{}{"flags": v.flags, "filter": v.filter}
+	}
+	config.GetConfig()[configViews] = saved
+	return config.WriteConfig()
+}
+
+// expandView replaces a leading @name in the list arguments with the flags
+// of that view and returns its filter expression. Without any arguments the
+// default view is used, if one is set.
+func expandView(args []string, config *Config) ([]string, string, error) {
+	var v *listView
+	var err error
+	if len(args) == 0 {
+		v, err = getView(config.GetValue(configDefaultView), config)
+	} else {
+		v, args, err = findView(args, config)
+	}
+	if err != nil || v == nil {
+		return args, "", err
+	}
+	// Flags given after the view override the saved ones
+	expanded := append(append([]string{}, v.flags...), args...)
+	return expanded, v.filter, nil
+}
+
+// findView returns the view named by a leading @name in args and the args
+// after the name. It is nil when args do not start with a name, the default
+// view is only applied by expandView.
+func findView(args []string, config *Config) (*listView, []string, error) {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "@") {
+		return nil, args, nil
+	}
+	v, err := getView(strings.TrimPrefix(args[0], "@"), config)
+	return v, args[1:], err
+}
+
+// getView returns the saved view called name, nil for no name
+func getView(name string, config *Config) (*listView, error) {
+	if name == "" {
+		return nil, nil
+	}
+	v, found := getViews(config)[name]
+	if !found {
+		return nil, fmt.Errorf("no view named %q, see todo views list", name)
+	}
+	return &v, nil
+}
+
+func views(f *flag.FlagSet, config *Config) {
+	usage := `Usage:
+  todo views save <name> [list flags] [filter expression]
+  todo views list
+  todo views del <name>
+  todo views default <name | none>`
+	args := os.Args[2:]
+	if len(args) < 1 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+
+	saved := getViews(config)
+	switch args[0] {
+	case "save":
+		if len(args) < 2 || !viewName.MatchString(args[1]) {
+			fmt.Println("A view needs a name made of letters, numbers, - and _")
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		// Check the view works as todo list arguments before saving it
+		f.Init("list", flag.ContinueOnError)
+		o := listFlags(f)
+		if err := f.Parse(args[2:]); err != nil {
+			os.Exit(1)
+		}
+		if _, err := o.buildFilter(f, ""); err != nil {
+			fmt.Println("Error in filter: ", err)
+			os.Exit(1)
+		}
+		flagArgs := append([]string{}, args[2:len(args)-f.NArg()]...)
+		saved[args[1]] = listView{flags: flagArgs, filter: strings.Join(f.Args(), " ")}
+		if err := writeViews(config, saved); err != nil {
+			fmt.Println("Error saving view: ", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved view %v, use it with todo list @%v\n", args[1], args[1])
+	case "list":
+		if len(saved) == 0 {
+			fmt.Println("No saved views")
+			return
+		}
+		var names []string
+		for name := range saved {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		defaultView := config.GetValue(configDefaultView)
+		for _, name := range names {
+			marker := " "
+			if name == defaultView {
+				marker = "*"
+			}
+			v := saved[name]
+			fmt.Printf("%v @%-15v %v", marker, name, strings.Join(v.flags, " "))
+			if v.filter != "" {
+				fmt.Printf(" '%v'", v.filter)
+			}
+			fmt.Println()
+		}
+	case "del":
+		if len(args) < 2 {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		if _, found := saved[args[1]]; !found {
+			fmt.Printf("No view named %q\n", args[1])
+			os.Exit(1)
+		}
+		delete(saved, args[1])
+		if err := writeViews(config, saved); err != nil {
+			fmt.Println("Error deleting view: ", err)
+			os.Exit(1)
+		}
+		if config.GetValue(configDefaultView) == args[1] {
+			if err := config.Delete(configDefaultView); err != nil {
+				fmt.Println("Error clearing default view: ", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Println("Deleted view: ", args[1])
+	case "default":
+		if len(args) < 2 {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		if args[1] == "none" {
+			if err := config.Delete(configDefaultView); err != nil {
+				fmt.Println("Error clearing default view: ", err)
+				os.Exit(1)
+			}
+			fmt.Println("Cleared default view")
+			return
+		}
+		if _, found := saved[args[1]]; !found {
+			fmt.Printf("No view named %q\n", args[1])
+			os.Exit(1)
+		}
+		if err := config.Set(configDefaultView, args[1]); err != nil {
+			fmt.Println("Error setting default view: ", err)
+			os.Exit(1)
+		}
+		fmt.Println("Default view: ", args[1])
+	default:
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testViewConfig() *Config {
+	return &Config{config: map[string]interface{}{
+		configViews: map[string]interface{}{
+			"top":  map[string]interface{}{"flags": []interface{}{"-l", "5"}, "filter": "priority = 3 limit:1"},
+			"work": map[string]interface{}{"flags": []interface{}{}, "filter": `tags = "work"`},
+		},
+		configDefaultView: "top",
+	}}
+}
+
+// The default view is only for a plain todo list, a view named with @ is
+// found wherever it is given
+func TestFindView(t *testing.T) {
+	config := testViewConfig()
+	tests := []struct {
+		args   []string
+		filter string
+		rest   []string
+	}{
+		{nil, "", nil},
+		{[]string{"-since", "-2w"}, "", []string{"-since", "-2w"}},
+		{[]string{"@work"}, `tags = "work"`, []string{}},
+		{[]string{"@work", "-since", "-2w"}, `tags = "work"`, []string{"-since", "-2w"}},
+	}
+	for _, test := range tests {
+		v, rest, err := findView(test.args, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filter := ""
+		if v != nil {
+			filter = v.filter
+		}
+		if filter != test.filter || !reflect.DeepEqual(rest, test.rest) {
+			t.Errorf("findView(%q) = %q, %q, want %q, %q", test.args, filter, rest, test.filter, test.rest)
+		}
+	}
+	if _, _, err := findView([]string{"@missing"}, config); err == nil || err.Error() != `no view named "missing", see todo views list` {
+		t.Errorf("findView(@missing) error = %v", err)
+	}
+}
+
+func TestExpandView(t *testing.T) {
+	config := testViewConfig()
+	tests := []struct {
+		args   []string
+		want   []string
+		filter string
+	}{
+		{nil, []string{"-l", "5"}, "priority = 3 limit:1"},
+		{[]string{"-p", "2"}, []string{"-p", "2"}, ""},
+		{[]string{"@work", "-l", "3"}, []string{"-l", "3"}, `tags = "work"`},
+		{[]string{"@top", "-l", "3"}, []string{"-l", "5", "-l", "3"}, "priority = 3 limit:1"},
+	}
+	for _, test := range tests {
+		got, filter, err := expandView(test.args, config)
+		if err != nil || !reflect.DeepEqual(got, test.want) || filter != test.filter {
+			t.Errorf("expandView(%q) = %q, %q, %v, want %q, %q", test.args, got, filter, err, test.want, test.filter)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// Text in the table is measured in terminal columns rather than bytes or
+// runes: wide East Asian characters take two columns, combining marks and
+// zero width joiners none, and an emoji sequence is kept together as one
+// grapheme cluster.
+
+// displayWidth is the number of terminal columns s takes up
+func displayWidth(s string) int {
+	return uniseg.StringWidth(s)
+}
+
+// wrapText breaks s into lines no wider than width. Lines are broken where
+// Unicode allows, between words or after a hyphen, and explicit newlines are
+// kept. A word wider than the line is split between grapheme clusters with
+// a hyphen where it splits a word.
+func wrapText(s string, width int) []string {
+	if width < 1 {
+		width = 1
+	}
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		lines = append(lines, wrapParagraph(paragraph, width)...)
+	}
+	return lines
+}
+
+func wrapParagraph(s string, width int) []string {
+	var lines []string
+	var line strings.Builder
+	lineWidth := 0
+	state := -1
+	for s != "" {
+		var segment string
+		segment, s, _, state = uniseg.FirstLineSegmentInString(s, state)
+		// Spaces at the end of a line are not shown
+		word := strings.TrimRight(segment, " ")
+		trailing := segment[len(word):]
+		wordWidth := displayWidth(word)
+
+		if lineWidth+wordWidth > width && lineWidth > 0 {
+			lines = append(lines, strings.TrimRight(line.String(), " "))
+			line.Reset()
+			lineWidth = 0
+		}
+		for wordWidth > width {
+			// Too long for a line of its own
+			head, rest := splitWord(word, width)
+			if rest == "" {
+				// A cluster wider than the line goes on it regardless
+				break
+			}
+			lines = append(lines, head)
+			word, segment = rest, rest+trailing
+			wordWidth = displayWidth(word)
+		}
+		line.WriteString(segment)
+		lineWidth += displayWidth(segment)
+	}
+	return append(lines, strings.TrimRight(line.String(), " "))
+}
+
+// splitWord takes as much of word as fits in width, ending with a hyphen
+// when the split falls between two letters
+func splitWord(word string, width int) (string, string) {
+	var clusters []string
+	var widths []int
+	state := -1
+	for rest := word; rest != ""; {
+		var cluster string
+		var w int
+		cluster, rest, w, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		clusters = append(clusters, cluster)
+		widths = append(widths, w)
+	}
+
+	fits, used := 0, 0
+	for fits < len(clusters) && used+widths[fits] <= width {
+		used += widths[fits]
+		fits++
+	}
+	if fits == 0 {
+		// A cluster wider than the line goes on it regardless
+		fits = 1
+	}
+	hyphen := ""
+	if width > 1 && fits < len(clusters) && isLetter(clusters[fits]) {
+		// Make room for the hyphen
+		if used+1 > width {
+			fits--
+		}
+		if fits > 0 && isLetter(clusters[fits-1]) {
+			hyphen = "-"
+		}
+		if fits == 0 {
+			fits = 1
+		}
+	}
+	return strings.Join(clusters[:fits], "") + hyphen, strings.Join(clusters[fits:], "")
+}
+
+func isLetter(cluster string) bool {
+	r, _ := utf8.DecodeRuneInString(cluster)
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// truncateText shortens s to width columns on one line, ending with … when
+// it is cut
+func truncateText(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if displayWidth(s) <= width {
+		return s
+	}
+	var b strings.Builder
+	used := 0
+	state := -1
+	for rest := s; rest != ""; {
+		var cluster string
+		var w int
+		cluster, rest, w, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		if used+w > width-1 {
+			break
+		}
+		b.WriteString(cluster)
+		used += w
+	}
+	return b.String() + "…"
+}
+
+// padText fills s with spaces to width columns, on the left when right is
+// set
+func padText(s string, width int, right bool) string {
+	fill := strings.Repeat(" ", maxInt(width-displayWidth(s), 0))
+	if right {
+		return fill + s
+	}
+	return s + fill
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"日本語", 6},
+		{"café", 4},
+		{"👩‍👩‍👧", 2},
+		{"✓ done", 6},
+	}
+	for _, test := range tests {
+		if got := displayWidth(test.s); got != test.want {
+			t.Errorf("displayWidth(%q) = %d, want %d", test.s, got, test.want)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  []string
+	}{
+		{"", 5, []string{""}},
+		{"hello world", 20, []string{"hello world"}},
+		{"hello world", 11, []string{"hello world"}},
+		{"hello world", 8, []string{"hello", "world"}},
+		{"hello world", 5, []string{"hello", "world"}},
+		{"a b c d", 3, []string{"a b", "c d"}},
+		{"one\ntwo", 10, []string{"one", "two"}},
+		{"one\r\n\r\ntwo", 10, []string{"one", "", "two"}},
+		{"well-known fact", 6, []string{"well-", "known", "fact"}},
+
+		// Words longer than the line are split, with a hyphen inside a word
+		{"abcdefghij", 4, []string{"abc-", "def-", "ghij"}},
+		{"go supercalifragilistic", 10, []string{"go", "supercali-", "fragilist-", "ic"}},
+		{"ab", 0, []string{"a", "b"}},
+
+		// Wide characters take two columns and may break between them
+		{"日本語テキスト", 6, []string{"日本語", "テキス", "ト"}},
+		{"日本語テキスト", 5, []string{"日本", "語テ", "キス", "ト"}},
+		{"日", 1, []string{"日"}},
+		{"a日b", 1, []string{"a", "日", "b"}},
+
+		// Grapheme clusters are never split
+		{"café ok", 7, []string{"café ok"}},
+		{"café ok", 5, []string{"café", "ok"}},
+		{"👩‍👩‍👧 family", 8, []string{"👩‍👩‍👧", "family"}},
+		{"👩‍👩‍👧👩‍👩‍👧👩‍👩‍👧", 4, []string{"👩‍👩‍👧👩‍👩‍👧", "👩‍👩‍👧"}},
+	}
+	for _, test := range tests {
+		got := wrapText(test.s, test.width)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("wrapText(%q, %d) = %q, want %q", test.s, test.width, got, test.want)
+		}
+		for _, line := range got {
+			if w := displayWidth(line); w > test.width && test.width > 1 {
+				t.Errorf("wrapText(%q, %d) line %q is %d wide", test.s, test.width, line, w)
+			}
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"hello world", 20, "hello world"},
+		{"hello world", 11, "hello world"},
+		{"hello world", 8, "hello w…"},
+		{"a  b\n c", 10, "a b c"},
+		{"日本語テキスト", 7, "日本語…"},
+		{"日本語テキスト", 6, "日本…"},
+		{"café au lait", 5, "café…"},
+		{"👩‍👩‍👧👩‍👩‍👧", 3, "👩‍👩‍👧…"},
+		{"hello", 1, "…"},
+	}
+	for _, test := range tests {
+		got := truncateText(test.s, test.width)
+		if got != test.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", test.s, test.width, got, test.want)
+		}
+		if w := displayWidth(got); w > test.width {
+			t.Errorf("truncateText(%q, %d) = %q is %d wide", test.s, test.width, got, w)
+		}
+	}
+}
+
+func TestPadText(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		right bool
+		want  string
+	}{
+		{"ab", 4, false, "ab  "},
+		{"ab", 4, true, "  ab"},
+		{"日本", 5, false, "日本 "},
+		{"toolong", 3, false, "toolong"},
+	}
+	for _, test := range tests {
+		if got := padText(test.s, test.width, test.right); got != test.want {
+			t.Errorf("padText(%q, %d, %v) = %q, want %q", test.s, test.width, test.right, got, test.want)
+		}
+	}
+}